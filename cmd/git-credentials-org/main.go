@@ -2,13 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/imcitius/git-credentials-org/internal/cache"
 	"github.com/imcitius/git-credentials-org/internal/config"
 	"github.com/imcitius/git-credentials-org/internal/handler"
+	"github.com/imcitius/git-credentials-org/internal/importer"
+	"github.com/imcitius/git-credentials-org/internal/store"
+	"golang.org/x/term"
 )
 
 var version = "dev"
@@ -19,6 +24,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	handler.Version = version
 	verbose := os.Getenv("GIT_CREDENTIALS_ORG_DEBUG") == "1"
 	configPath := os.Getenv("GIT_CREDENTIALS_ORG_CONFIG")
 	if configPath == "" {
@@ -47,9 +53,16 @@ func main() {
 		runCredentialOp(operation, configPath, verbose)
 	case "install":
 		runInstall()
+	case "cache":
+		runCache(args[1:], configPath)
 	case "list":
-		fmt.Fprintln(os.Stderr, "list: not yet implemented (requires backend-specific enumeration)")
-		os.Exit(1)
+		runList(args[1:], configPath, verbose)
+	case "set", "show", "delete":
+		runManage(operation, args[1:], configPath, verbose)
+	case "migrate":
+		runMigrate(args[1:], configPath, verbose)
+	case "import":
+		runImport(args[1:], configPath, verbose)
 	case "version", "--version":
 		fmt.Printf("git-credentials-org %s\n", version)
 	case "help", "--help", "-h":
@@ -89,6 +102,276 @@ func runCredentialOp(op, configPath string, verbose bool) {
 	}
 }
 
+// runList prints the stored credentials, never their secrets.
+func runList(args []string, configPath string, verbose bool) {
+	asJSON := false
+	backend := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--json":
+			asJSON = true
+		case args[i] == "--backend" && i+1 < len(args):
+			i++
+			backend = args[i]
+		default:
+			fmt.Fprintln(os.Stderr, "usage: git-credentials-org list [--json] [--backend name]")
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	entries, warnings, err := handler.New(cfg, verbose).List(backend)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	for _, e := range entries {
+		if e.Error != "" {
+			fmt.Fprintf(os.Stderr, "warning: %s in %s can't be read: %s\n", e.Namespace, e.Backend, e.Error)
+		}
+	}
+	if err := handler.WriteList(os.Stdout, entries, asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runManage handles set, show and delete of a single namespace.
+func runManage(op string, args []string, configPath string, verbose bool) {
+	usage := map[string]string{
+		"set":    "usage: git-credentials-org set <namespace> [--host name] [--username name]  (token from stdin or prompt)",
+		"show":   "usage: git-credentials-org show <namespace> [--host name] [--reveal]",
+		"delete": "usage: git-credentials-org delete <namespace> [--host name]",
+	}[op]
+
+	var namespace, host, username string
+	reveal := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--host" && i+1 < len(args):
+			i++
+			host = args[i]
+		case op == "set" && args[i] == "--username" && i+1 < len(args):
+			i++
+			username = args[i]
+		case op == "show" && args[i] == "--reveal":
+			reveal = true
+		case namespace == "" && !strings.HasPrefix(args[i], "-"):
+			namespace = args[i]
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+	if namespace == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	h := handler.New(cfg, verbose)
+
+	switch op {
+	case "set":
+		// Read a piped token; prompt on a terminal.
+		var token io.Reader
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			token = os.Stdin
+		}
+		err = h.Set(namespace, host, token, username)
+	case "show":
+		var entry handler.ListEntry
+		var cred *store.Credential
+		entry, cred, err = h.Show(namespace, host)
+		if err == nil {
+			err = handler.WriteShow(os.Stdout, entry, cred, reveal)
+		}
+	case "delete":
+		err = h.Delete(namespace, host)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runMigrate copies credentials between backends and reports the outcome
+// for each namespace.
+func runMigrate(args []string, configPath string, verbose bool) {
+	const usage = "usage: git-credentials-org migrate --from <backend> --to <backend> [--namespace pattern] [--delete-source] [--dry-run]"
+
+	var opts handler.MigrateOptions
+	for i := 0; i < len(args); i++ {
+		hasValue := i+1 < len(args)
+		switch {
+		case args[i] == "--from" && hasValue:
+			i++
+			opts.From = args[i]
+		case args[i] == "--to" && hasValue:
+			i++
+			opts.To = args[i]
+		case args[i] == "--namespace" && hasValue:
+			i++
+			opts.Namespace = args[i]
+		case args[i] == "--delete-source":
+			opts.DeleteSource = true
+		case args[i] == "--dry-run":
+			opts.DryRun = true
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+	if opts.From == "" || opts.To == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	results, err := handler.New(cfg, verbose).Migrate(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := handler.WriteMigrateReport(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runImport(args []string, configPath string, verbose bool) {
+	const usage = "usage: git-credentials-org import [--from git-credentials,osxkeychain,libsecret] [--file path] [--strategy interactive|skip|first|last] [--dry-run]"
+
+	sources := []string{importer.SourceGitCredentials}
+	files := importer.DefaultGitCredentialsPaths()
+	var opts handler.ImportOptions
+	for i := 0; i < len(args); i++ {
+		hasValue := i+1 < len(args)
+		switch {
+		case args[i] == "--from" && hasValue:
+			i++
+			sources = strings.Split(args[i], ",")
+		case args[i] == "--file" && hasValue:
+			i++
+			files = []string{config.ExpandPath(args[i])}
+		case args[i] == "--strategy" && hasValue:
+			i++
+			opts.Strategy = args[i]
+		case args[i] == "--dry-run":
+			opts.DryRun = true
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	h := handler.New(cfg, verbose)
+
+	var entries []importer.Entry
+	for _, source := range sources {
+		var found []importer.Entry
+		switch strings.TrimSpace(source) {
+		case importer.SourceGitCredentials:
+			for _, file := range files {
+				var read []importer.Entry
+				if read, err = importer.ReadGitCredentials(file); err != nil {
+					break
+				}
+				found = append(found, read...)
+			}
+		case importer.SourceOSXKeychain:
+			found, err = importer.ReadOSXKeychain(nil, h.ImportsHost)
+		case importer.SourceLibsecret:
+			found, err = importer.ReadLibsecret(store.SearchSecretService)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown import source %q\n", source)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", source, err)
+			os.Exit(1)
+		}
+		entries = append(entries, found...)
+	}
+
+	results, err := h.Import(entries, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := handler.WriteImportReport(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runCache handles "cache exit" and "cache daemon", the latter being
+// started by the helper itself when there is something to cache.
+func runCache(args []string, configPath string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: git-credentials-org cache exit")
+		os.Exit(1)
+	}
+
+	socket := ""
+	if len(args) == 3 && args[1] == "--socket" {
+		socket = args[2]
+	} else {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		socket = config.ExpandPath(cfg.Cache.Socket)
+	}
+	if socket == "" {
+		socket = cache.SocketPath()
+	}
+
+	switch args[0] {
+	case "exit":
+		if err := cache.NewClient(socket).Exit(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "daemon":
+		srv, err := cache.Listen(socket)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := srv.Serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
 func runInstall() {
 	self, err := os.Executable()
 	if err != nil {
@@ -143,6 +426,32 @@ provider = "github"
 	fmt.Fprintf(os.Stderr, "Installed git-credentials-org as global credential helper.\n")
 	fmt.Fprintf(os.Stderr, "  helper: %s\n", self)
 	fmt.Fprintf(os.Stderr, "  config: %s\n", configPath)
+
+	warnUseHTTPPathOverrides()
+}
+
+// warnUseHTTPPathOverrides reports any credential.useHttpPath (including
+// URL-scoped credential.<url>.useHttpPath) set to false somewhere in the
+// config stack, since that hides the path namespaces are derived from.
+func warnUseHTTPPathOverrides() {
+	cmd := exec.Command("git", "config", "--show-origin", "--get-regexp", `^credential\..*usehttppath$`)
+	out, err := cmd.Output()
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		origin, entry, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, " ")
+		switch strings.ToLower(value) {
+		case "false", "no", "off", "0":
+			fmt.Fprintf(os.Stderr, "warning: %s sets %s=%s; git will not send repository paths there,\n", origin, key, value)
+			fmt.Fprintf(os.Stderr, "         so namespaces fall back to remote inference or the bare host\n")
+		}
+	}
 }
 
 func printUsage() {
@@ -152,7 +461,16 @@ A Git credential helper that resolves credentials by organization namespace.
 
 Usage:
   git-credentials-org <get|store|erase>   Git credential helper operations
+  git-credentials-org list [--json]       List stored credentials (never their secrets)
+  git-credentials-org set <namespace>     Store a token, read from stdin or prompted for
+  git-credentials-org show <namespace>    Show a credential with its secret masked (--reveal to print it)
+  git-credentials-org delete <namespace>  Delete a credential
+  git-credentials-org migrate --from <backend> --to <backend> [--namespace pattern] [--delete-source] [--dry-run]
+                                          Copy credentials between backends
+  git-credentials-org import [--from git-credentials,osxkeychain,libsecret] [--strategy interactive|skip|first|last] [--dry-run]
+                                          Import credentials saved by git's own helpers
   git-credentials-org install             Configure git to use this helper
+  git-credentials-org cache exit          Stop the cache daemon, dropping cached credentials
   git-credentials-org version             Print version
   git-credentials-org help                Print this help
 
@@ -2,12 +2,17 @@ module github.com/imcitius/git-credentials-org
 
 go 1.24.2
 
+require (
+	filippo.io/age v1.2.1
+	github.com/BurntSushi/toml v1.6.0
+	github.com/danieljoos/wincred v1.2.2
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/zalando/go-keyring v0.2.6
+	golang.org/x/crypto v0.24.0
+	golang.org/x/term v0.40.0
+)
+
 require (
 	al.essio.dev/pkg/shellescape v1.5.1 // indirect
-	github.com/BurntSushi/toml v1.6.0 // indirect
-	github.com/danieljoos/wincred v1.2.2 // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
-	github.com/zalando/go-keyring v0.2.6 // indirect
 	golang.org/x/sys v0.41.0 // indirect
-	golang.org/x/term v0.40.0 // indirect
 )
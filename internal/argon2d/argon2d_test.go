@@ -0,0 +1,48 @@
+package argon2d
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestKey(t *testing.T) {
+	// RFC 9106, section 5.1.
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+	want := "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"
+	if got := hex.EncodeToString(Key(password, salt, secret, data, 3, 32, 4, 32)); got != want {
+		t.Errorf("Key(RFC 9106) = %s, want %s", got, want)
+	}
+
+	// Generated with the reference implementation's CLI.
+	tests := []struct {
+		time, memory uint32
+		threads      uint8
+		want         string
+	}{
+		{1, 64, 1, "8727405fd07c32c78d64f547f24150d3f2e703a89f981a19"},
+		{2, 64, 2, "68e2462c98b8bc6bb60ec68db418ae2c9ed24fc6748a40e9"},
+		{3, 256, 2, "f4f0669218eaf3641f39cc97efb915721102f4b128211ef2"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(Key([]byte("password"), []byte("somesalt"), nil, nil, tt.time, tt.memory, tt.threads, 24))
+		if got != tt.want {
+			t.Errorf("Key(t=%d, m=%d, p=%d) = %s, want %s", tt.time, tt.memory, tt.threads, got, tt.want)
+		}
+	}
+}
+
+// The block function is the generic one; check it against x/crypto's,
+// which may use assembly, through the Argon2id mode both implement.
+func TestMatchesXCrypto(t *testing.T) {
+	password, salt := []byte("password"), []byte("somesalt")
+	want := argon2.IDKey(password, salt, 2, 1024, 2, 32)
+	if got := deriveKey(argon2id, password, salt, nil, nil, 2, 1024, 2, 32); !bytes.Equal(got, want) {
+		t.Errorf("deriveKey(argon2id) = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Package cache keeps credentials in memory in a per-user daemon in front
+// of slow backends, like git-credential-cache. The helper talks to the
+// daemon over a Unix socket with one JSON request and response per
+// connection.
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+type request struct {
+	Op         string            `json:"op"` // get, put, delete or exit
+	Key        string            `json:"key,omitempty"`
+	Credential *store.Credential `json:"credential,omitempty"`
+	TTL        time.Duration     `json:"ttl,omitempty"`
+}
+
+type response struct {
+	// Found reports a cached entry; Credential is nil for a cached miss.
+	Found      bool              `json:"found,omitempty"`
+	Credential *store.Credential `json:"credential,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// SocketPath returns the default daemon socket:
+// $XDG_RUNTIME_DIR/git-credentials-org/cache.sock, else, like
+// git-credential-cache, a directory in the user's cache directory rather
+// than the shared temp dir, where another user could create it first.
+func SocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "git-credentials-org", "cache.sock")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "git-credentials-org", "cache.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("git-credentials-org-%d", os.Getuid()), "cache.sock")
+}
+
+// checkSocketDir creates the socket directory, refusing one other users
+// can get into.
+func checkSocketDir(socket string) error {
+	dir := filepath.Dir(socket)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return verifySocketDir(dir)
+}
+
+// verifySocketDir checks that dir is a directory of ours that nobody else
+// can enter, so the socket in it is the daemon we started and not one
+// another user set up to collect credentials.
+func verifySocketDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("%s is owned by uid %d, not by you", dir, uid)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s has permissions %04o, want 0700", dir, perm)
+	}
+	return nil
+}
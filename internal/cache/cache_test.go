@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// testSocket returns a socket path short enough for the sun_path limit.
+func testSocket(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "gco")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "run", "cache.sock")
+}
+
+// startServer runs a daemon on socket until the test ends.
+func startServer(t *testing.T, socket string) *Server {
+	srv, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestClientServer(t *testing.T) {
+	socket := testSocket(t)
+	srv := startServer(t, socket)
+	now := time.Unix(1700000000, 0)
+	srv.now = func() time.Time { return now }
+
+	c := NewClient(socket)
+	cred := &store.Credential{Username: "oauth2", Password: "glpat-abc"}
+	if err := c.Put("keychain:gitlab.com/org1", cred, time.Minute); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := c.Put("keychain:gitlab.com/org2", nil, time.Minute); err != nil {
+		t.Fatalf("Put(miss) error = %v", err)
+	}
+
+	got, found, err := c.Get("keychain:gitlab.com/org1")
+	if err != nil || !found || *got != *cred {
+		t.Errorf("Get() = %+v, %v, %v, want cached credential", got, found, err)
+	}
+	if got, found, err := c.Get("keychain:gitlab.com/org2"); err != nil || !found || got != nil {
+		t.Errorf("Get() = %+v, %v, %v, want cached miss", got, found, err)
+	}
+	if _, found, _ := c.Get("keychain:gitlab.com/org3"); found {
+		t.Error("Get() found an entry never put")
+	}
+
+	if err := c.Delete("keychain:gitlab.com/org1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, found, _ := c.Get("keychain:gitlab.com/org1"); found {
+		t.Error("Get() found a deleted entry")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, found, _ := c.Get("keychain:gitlab.com/org2"); found {
+		t.Error("Get() found an entry past its TTL")
+	}
+}
+
+func TestClientWithoutDaemon(t *testing.T) {
+	socket := testSocket(t)
+	c := NewClient(socket)
+
+	if _, found, err := c.Get("keychain:gitlab.com/org1"); err != nil || found {
+		t.Errorf("Get() = %v, %v, want a plain miss", found, err)
+	}
+	if err := c.Delete("keychain:gitlab.com/org1"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err := c.Exit(); err != nil {
+		t.Errorf("Exit() error = %v", err)
+	}
+
+	// Put starts the daemon.
+	c.spawn = func(socket string) error {
+		startServer(t, socket)
+		return nil
+	}
+	if err := c.Put("keychain:gitlab.com/org1", &store.Credential{Password: "glpat-abc"}, time.Minute); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, found, err := c.Get("keychain:gitlab.com/org1"); err != nil || !found {
+		t.Errorf("Get() = %v, %v, want entry from spawned daemon", found, err)
+	}
+}
+
+func TestExit(t *testing.T) {
+	socket := testSocket(t)
+	srv, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve() }()
+
+	if _, err := Listen(socket); err == nil {
+		t.Error("Listen() should refuse a socket a daemon is serving")
+	}
+
+	if err := NewClient(socket).Exit(); err != nil {
+		t.Fatalf("Exit() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon didn't exit")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
+
+func TestClientRejectsForeignSocketDir(t *testing.T) {
+	tests := map[string]func(dir string) error{
+		"open to others": func(dir string) error { return os.Chmod(dir, 0755) },
+	}
+	if os.Getuid() == 0 {
+		tests["owned by another user"] = func(dir string) error { return os.Chown(dir, 4242, 4242) }
+	}
+
+	for name, prepare := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Someone else's listener, waiting for secrets.
+			socket := testSocket(t)
+			if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			l, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			defer l.Close()
+			accepted := make(chan struct{}, 1)
+			go func() {
+				if conn, err := l.Accept(); err == nil {
+					accepted <- struct{}{}
+					conn.Close()
+				}
+			}()
+			if err := prepare(filepath.Dir(socket)); err != nil {
+				t.Fatalf("preparing socket dir: %v", err)
+			}
+
+			c := NewClient(socket)
+			c.spawn = func(string) error {
+				t.Error("Put() started a daemon")
+				return nil
+			}
+			if err := c.Put("keychain:gitlab.com/org1", &store.Credential{Password: "glpat-abc"}, time.Minute); err == nil {
+				t.Error("Put() should refuse the socket")
+			}
+			if _, _, err := c.Get("keychain:gitlab.com/org1"); err == nil {
+				t.Error("Get() should refuse the socket")
+			}
+			select {
+			case <-accepted:
+				t.Error("client connected to the socket")
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestListenRejectsOpenSocketDir(t *testing.T) {
+	socket := testSocket(t)
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.Chmod(filepath.Dir(socket), 0755); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	if _, err := Listen(socket); err == nil {
+		t.Error("Listen() should refuse a directory other users can enter")
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// Client talks to the cache daemon, starting it when there is something
+// to cache. It implements store.Cache.
+type Client struct {
+	socket string
+
+	// spawn starts the daemon; replaced in tests.
+	spawn func(socket string) error
+}
+
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = SocketPath()
+	}
+	return &Client{socket: socket, spawn: spawnDaemon}
+}
+
+// Get returns the cached entry for key. A daemon that isn't running is a
+// miss, not an error.
+func (c *Client) Get(key string) (*store.Credential, bool, error) {
+	resp, err := c.call(request{Op: "get", Key: key})
+	if err != nil {
+		if isNotRunning(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return resp.Credential, resp.Found, nil
+}
+
+// Put caches cred (nil for a miss) under key for ttl, starting the daemon
+// if needed.
+func (c *Client) Put(key string, cred *store.Credential, ttl time.Duration) error {
+	req := request{Op: "put", Key: key, Credential: cred, TTL: ttl}
+	_, err := c.call(req)
+	if err == nil || !isNotRunning(err) {
+		return err
+	}
+
+	if err := c.spawn(c.socket); err != nil {
+		return fmt.Errorf("starting cache daemon: %w", err)
+	}
+	// Wait for the daemon to bind its socket.
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		_, err = c.call(req)
+		if err == nil || !isNotRunning(err) || time.Now().After(deadline) {
+			return err
+		}
+	}
+}
+
+// Delete drops key from the cache.
+func (c *Client) Delete(key string) error {
+	_, err := c.call(request{Op: "delete", Key: key})
+	if isNotRunning(err) {
+		return nil
+	}
+	return err
+}
+
+// Exit stops the daemon, forgetting everything it cached.
+func (c *Client) Exit() error {
+	_, err := c.call(request{Op: "exit"})
+	if isNotRunning(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) call(req request) (*response, error) {
+	// A missing directory means no daemon; dialing reports that.
+	if err := verifySocketDir(filepath.Dir(c.socket)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("refusing cache socket: %w", err)
+	}
+
+	conn, err := net.DialTimeout("unix", c.socket, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading cache response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New("cache: " + resp.Error)
+	}
+	return &resp, nil
+}
+
+// isNotRunning reports a socket nobody is listening on.
+func isNotRunning(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// spawnDaemon starts "git-credentials-org cache daemon" detached from the
+// calling git process, with no stdio so git doesn't wait for it.
+func spawnDaemon(socket string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(self, "cache", "daemon", "--socket", socket)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
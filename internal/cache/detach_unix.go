@@ -0,0 +1,14 @@
+//go:build unix
+
+package cache
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach runs the daemon in its own session, so it outlives the git
+// command and isn't hit by its terminal's signals.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
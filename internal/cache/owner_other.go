@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
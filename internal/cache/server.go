@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// sweepInterval is how often expired entries are dropped; the daemon
+// exits at a sweep that leaves the cache empty.
+const sweepInterval = 30 * time.Second
+
+// Server is the cache daemon.
+type Server struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type entry struct {
+	cred    *store.Credential
+	expires time.Time
+}
+
+// Listen binds the daemon socket, replacing a stale one. It fails if a
+// daemon is already serving it.
+func Listen(socket string) (*Server, error) {
+	if err := checkSocketDir(socket); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New("cache daemon already running on " + socket)
+	}
+	os.Remove(socket)
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return &Server{
+		listener: l,
+		entries:  make(map[string]entry),
+		now:      time.Now,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Serve answers requests until an exit request, Close, or a sweep finding
+// the cache empty.
+func (s *Server) Serve() error {
+	go s.sweep()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops the daemon and removes its socket.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.listener.Close()
+	})
+	return err
+}
+
+func (s *Server) sweep() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		now := s.now()
+		for key, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, key)
+			}
+		}
+		empty := len(s.entries) == 0
+		s.mu.Unlock()
+
+		if empty {
+			s.Close()
+			return
+		}
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(response{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	resp := s.apply(req)
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == "exit" {
+		s.Close()
+	}
+}
+
+func (s *Server) apply(req request) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case "get":
+		e, ok := s.entries[req.Key]
+		if !ok || !s.now().Before(e.expires) {
+			delete(s.entries, req.Key)
+			return response{}
+		}
+		return response{Found: true, Credential: e.cred}
+	case "put":
+		if req.TTL <= 0 {
+			delete(s.entries, req.Key)
+			return response{}
+		}
+		s.entries[req.Key] = entry{cred: req.Credential, expires: s.now().Add(req.TTL)}
+		return response{}
+	case "delete":
+		delete(s.entries, req.Key)
+		return response{}
+	case "exit":
+		return response{}
+	}
+	return response{Error: "unknown op " + req.Op}
+}
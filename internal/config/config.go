@@ -2,31 +2,130 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	Defaults DefaultsConfig            `toml:"defaults"`
-	Hosts    map[string]HostConfig     `toml:"hosts"`
-	Backends map[string]BackendConfig  `toml:"backends"`
+	Defaults DefaultsConfig           `toml:"defaults"`
+	Hosts    map[string]HostConfig    `toml:"hosts"`
+	Backends map[string]BackendConfig `toml:"backends"`
+	Cache    CacheConfig              `toml:"cache"`
+
+	// Namespaces are explicit mapping rules, evaluated in order before the
+	// host + path segments heuristic.
+	Namespaces []NamespaceRule `toml:"namespaces"`
 }
 
 type DefaultsConfig struct {
 	Backend  string `toml:"backend"`
 	LogLevel string `toml:"log_level"`
+
+	ChainConfig
+}
+
+// ChainConfig combines several backends: get tries Backends in order and
+// store writes according to WritePolicy: "first" (default), "all", or
+// "primary" for the backend named Primary. With Backfill, a credential
+// found in a later backend is copied into the earlier ones.
+type ChainConfig struct {
+	Backends    []string `toml:"backends"`
+	WritePolicy string   `toml:"write_policy"`
+	Primary     string   `toml:"primary"`
+	Backfill    *bool    `toml:"backfill"`
 }
 
 type HostConfig struct {
 	Provider string `toml:"provider"`
 	Backend  string `toml:"backend"`
+	ChainConfig
+
+	// NamespaceDepth is the number of leading path segments that make up
+	// a namespace on this host (e.g. 2 for GitLab subgroups). Defaults to 1.
+	NamespaceDepth int `toml:"namespace_depth"`
+
+	// StoreDepth is the namespace depth at which "store" saves a credential
+	// that doesn't already exist at some level. Defaults to NamespaceDepth;
+	// set it lower to save freshly entered tokens at group level.
+	StoreDepth int `toml:"store_depth"`
+
+	// Aliases are other names for this host (additional DNS names, IPs,
+	// host:port forms). They share its namespaces, provider and backend.
+	Aliases []string `toml:"aliases"`
+}
+
+// NamespaceRule maps repositories matching a glob (Match) or regular
+// expression (Regex) on "host/path" to a fixed namespace.
+type NamespaceRule struct {
+	Match     string `toml:"match"`
+	Regex     string `toml:"regex"`
+	Namespace string `toml:"namespace"`
+}
+
+// CacheConfig enables the cache daemon, which keeps credentials in memory
+// for TTL (a duration such as "15m"). Namespaces overrides the TTL for a
+// namespace and the namespaces below it; "0" disables caching there.
+type CacheConfig struct {
+	Enabled    bool              `toml:"enabled"`
+	TTL        string            `toml:"ttl"`
+	Socket     string            `toml:"socket"`
+	Namespaces map[string]string `toml:"namespaces"`
 }
 
+// DefaultCacheTTL is how long the cache daemon keeps a credential unless
+// configured otherwise.
+const DefaultCacheTTL = 15 * time.Minute
+
 type BackendConfig struct {
+	// Type selects the backend implementation; it defaults to the
+	// backend's name.
+	Type string `toml:"type"`
+
 	Vault   string `toml:"vault"`
 	Account string `toml:"account"`
+
+	// Secret Service: collection name or alias, item label template
+	// ({namespace}, {host}, {provider}) and extra item attributes.
+	Collection string            `toml:"collection"`
+	Label      string            `toml:"label"`
+	Attributes map[string]string `toml:"attributes"`
+
+	// Bitwarden: folder and organization (name or ID); Collection above
+	// names an organization collection.
+	Folder       string `toml:"folder"`
+	Organization string `toml:"organization"`
+
+	// File: encrypted store location, age identity file, or a file holding
+	// the passphrase. For pass, Path is the entry path template.
+	Path           string `toml:"path"`
+	Identity       string `toml:"identity"`
+	PassphraseFile string `toml:"passphrase_file"`
+
+	// KeePass: Path is the KDBX database, Group the group holding entries
+	// ("Git/Work" for nested groups) and KeyFile an optional key file; the
+	// password comes from PassphraseFile.
+	Group   string `toml:"group"`
+	KeyFile string `toml:"key_file"`
+
+	// Vault: server address, KV v2 mount and token file; Path is the
+	// secret path template. ReadOnly backends never store or erase.
+	Address   string `toml:"address"`
+	Mount     string `toml:"mount"`
+	TokenFile string `toml:"token_file"`
+	ReadOnly  bool   `toml:"read_only"`
+
+	// Command overrides the CLI a backend shells out to (e.g. "gopass"
+	// for the pass backend); for exec it is the plugin, run with Args.
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
 }
 
 func DefaultConfigPath() string {
@@ -37,6 +136,17 @@ func DefaultConfigPath() string {
 	return filepath.Join(home, ".config", "git-credentials-org", "config.toml")
 }
 
+// ExpandPath expands a leading "~/" in a configured path to the user's
+// home directory.
+func ExpandPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
 func Load(path string) (*Config, error) {
 	cfg := &Config{
 		Defaults: DefaultsConfig{
@@ -59,16 +169,175 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config %s: %w", path, err)
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
 	return cfg, nil
 }
 
+func (c *Config) validate() error {
+	aliasOf := make(map[string]string)
+	for host, hc := range c.Hosts {
+		for _, alias := range hc.Aliases {
+			key := strings.ToLower(alias)
+			if _, ok := c.Hosts[alias]; ok {
+				return fmt.Errorf("hosts.%q: alias %q is also configured as a host", host, alias)
+			}
+			if other, ok := aliasOf[key]; ok {
+				return fmt.Errorf("hosts.%q: alias %q is already an alias of %q", host, alias, other)
+			}
+			aliasOf[key] = host
+		}
+
+		if hc.Backend != "" && len(hc.Backends) > 0 {
+			return fmt.Errorf("hosts.%q: set only one of backend or backends", host)
+		}
+		if err := c.ChainForHost(host).validate(); err != nil {
+			return fmt.Errorf("hosts.%q: %w", host, err)
+		}
+	}
+
+	if c.Defaults.WritePolicy != "" || len(c.Defaults.Backends) > 0 {
+		if err := c.ChainForHost("").validate(); err != nil {
+			return fmt.Errorf("defaults: %w", err)
+		}
+	}
+
+	if c.Cache.TTL != "" {
+		if _, err := time.ParseDuration(c.Cache.TTL); err != nil {
+			return fmt.Errorf("cache: bad ttl: %w", err)
+		}
+	}
+	for ns, ttl := range c.Cache.Namespaces {
+		if _, err := time.ParseDuration(ttl); err != nil {
+			return fmt.Errorf("cache.namespaces.%q: bad ttl: %w", ns, err)
+		}
+	}
+
+	for i, r := range c.Namespaces {
+		if r.Namespace == "" {
+			return fmt.Errorf("namespaces[%d]: namespace is required", i)
+		}
+		switch {
+		case r.Match != "" && r.Regex != "":
+			return fmt.Errorf("namespaces[%d]: set only one of match or regex", i)
+		case r.Match != "":
+			if _, err := path.Match(r.Match, ""); err != nil {
+				return fmt.Errorf("namespaces[%d]: bad match pattern %q: %w", i, r.Match, err)
+			}
+		case r.Regex != "":
+			if _, err := regexp.Compile(r.Regex); err != nil {
+				return fmt.Errorf("namespaces[%d]: bad regex: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("namespaces[%d]: match or regex is required", i)
+		}
+	}
+	return nil
+}
+
+// CanonicalHost returns the configured host that host is an alias of,
+// or host itself if it isn't an alias.
+func (c *Config) CanonicalHost(host string) string {
+	for canonical, hc := range c.Hosts {
+		for _, alias := range hc.Aliases {
+			if strings.EqualFold(alias, host) {
+				return canonical
+			}
+		}
+	}
+	return host
+}
+
 // BackendForHost returns the backend name to use for a given host,
-// falling back to the default backend.
+// falling back to the default backend. For a chain it is the first
+// backend.
 func (c *Config) BackendForHost(host string) string {
-	if hc, ok := c.Hosts[host]; ok && hc.Backend != "" {
-		return hc.Backend
+	return c.BackendsForHost(host)[0]
+}
+
+// BackendsForHost returns the backend chain for a host: its backends or
+// backend, else the default backends or backend.
+func (c *Config) BackendsForHost(host string) []string {
+	if hc, ok := c.Hosts[host]; ok {
+		if len(hc.Backends) > 0 {
+			return hc.Backends
+		}
+		if hc.Backend != "" {
+			return []string{hc.Backend}
+		}
+	}
+	if len(c.Defaults.Backends) > 0 {
+		return c.Defaults.Backends
+	}
+	return []string{c.Defaults.Backend}
+}
+
+// BackendNames returns every backend the defaults or a host uses, in the
+// order of the defaults and then the hosts by name.
+func (c *Config) BackendNames() []string {
+	names := slices.Clone(c.BackendsForHost(""))
+	for _, host := range slices.Sorted(maps.Keys(c.Hosts)) {
+		for _, name := range c.BackendsForHost(host) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func (c ChainConfig) validate() error {
+	switch c.WritePolicy {
+	case "", "first", "all":
+	case "primary":
+		// A single backend (e.g. a host overriding the default chain)
+		// is its own primary.
+		if len(c.Backends) > 1 && !slices.Contains(c.Backends, c.Primary) {
+			return fmt.Errorf("primary %q is not one of backends %v", c.Primary, c.Backends)
+		}
+	default:
+		return fmt.Errorf("unknown write_policy %q (want first, all or primary)", c.WritePolicy)
+	}
+	return nil
+}
+
+// ChainForHost returns the host's backend chain and how it is written,
+// with unset host settings taken from the defaults.
+func (c *Config) ChainForHost(host string) ChainConfig {
+	chain := c.Defaults.ChainConfig
+	if hc, ok := c.Hosts[host]; ok {
+		if hc.WritePolicy != "" {
+			chain.WritePolicy, chain.Primary = hc.WritePolicy, hc.Primary
+		}
+		if hc.Backfill != nil {
+			chain.Backfill = hc.Backfill
+		}
+	}
+	chain.Backends = c.BackendsForHost(host)
+	return chain
+}
+
+// CacheTTL returns how long the cache daemon keeps the credential of a
+// namespace: the TTL set for the namespace or its nearest parent, else
+// the cache TTL.
+func (c *Config) CacheTTL(namespace string) time.Duration {
+	for ns := namespace; ns != ""; {
+		if s, ok := c.Cache.Namespaces[ns]; ok {
+			ttl, _ := time.ParseDuration(s)
+			return ttl
+		}
+		i := strings.LastIndex(ns, "/")
+		if i < 0 {
+			break
+		}
+		ns = ns[:i]
 	}
-	return c.Defaults.Backend
+	if ttl, err := time.ParseDuration(c.Cache.TTL); err == nil {
+		return ttl
+	}
+	return DefaultCacheTTL
 }
 
 // ProviderForHost returns the provider name for a given host.
@@ -79,3 +348,22 @@ func (c *Config) ProviderForHost(host string) string {
 	}
 	return ""
 }
+
+// NamespaceDepthForHost returns the number of path segments that form a
+// namespace on the given host. Returns 1 unless configured otherwise.
+func (c *Config) NamespaceDepthForHost(host string) int {
+	if hc, ok := c.Hosts[host]; ok && hc.NamespaceDepth > 0 {
+		return hc.NamespaceDepth
+	}
+	return 1
+}
+
+// StoreDepthForHost returns the namespace depth at which new credentials
+// are saved on the given host, falling back to its namespace depth.
+func (c *Config) StoreDepthForHost(host string) int {
+	depth := c.NamespaceDepthForHost(host)
+	if hc, ok := c.Hosts[host]; ok && hc.StoreDepth > 0 && hc.StoreDepth < depth {
+		return hc.StoreDepth
+	}
+	return depth
+}
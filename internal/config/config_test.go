@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoadMissing(t *testing.T) {
@@ -33,9 +35,22 @@ backend = "keychain"
 [hosts."github.com"]
 provider = "github"
 
+[hosts."gitlab.company.com"]
+provider = "gitlab"
+namespace_depth = 2
+aliases = ["gitlab-ssh.company.com", "10.1.2.3:8443"]
+
 [backends.onepassword]
 vault = "DevVault"
 account = "team.1password.com"
+
+[[namespaces]]
+match = "github.com/acme-*"
+namespace = "acme"
+
+[[namespaces]]
+regex = '^gitlab\.com/acme/legacy-'
+namespace = "acme-legacy"
 `
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		t.Fatalf("WriteFile() error = %v", err)
@@ -65,6 +80,20 @@ account = "team.1password.com"
 		}
 	}
 
+	if got := cfg.Hosts["gitlab.company.com"].NamespaceDepth; got != 2 {
+		t.Errorf("gitlab.company.com namespace_depth = %d, want 2", got)
+	}
+
+	if len(cfg.Namespaces) != 2 {
+		t.Fatalf("len(namespaces) = %d, want 2", len(cfg.Namespaces))
+	}
+	if r := cfg.Namespaces[0]; r.Match != "github.com/acme-*" || r.Namespace != "acme" {
+		t.Errorf("namespaces[0] = %+v", r)
+	}
+	if r := cfg.Namespaces[1]; r.Regex != `^gitlab\.com/acme/legacy-` || r.Namespace != "acme-legacy" {
+		t.Errorf("namespaces[1] = %+v", r)
+	}
+
 	if bc, ok := cfg.Backends["onepassword"]; !ok {
 		t.Error("missing backends.onepassword")
 	} else {
@@ -106,3 +135,235 @@ func TestProviderForHost(t *testing.T) {
 		t.Errorf("ProviderForHost(unknown.com) = %q, want %q", got, "")
 	}
 }
+
+func TestNamespaceDepthForHost(t *testing.T) {
+	cfg := &Config{
+		Hosts: map[string]HostConfig{
+			"gitlab.company.com": {Provider: "gitlab", NamespaceDepth: 2},
+			"gitlab.com":         {Provider: "gitlab"},
+		},
+	}
+
+	if got := cfg.NamespaceDepthForHost("gitlab.company.com"); got != 2 {
+		t.Errorf("NamespaceDepthForHost(gitlab.company.com) = %d, want 2", got)
+	}
+
+	if got := cfg.NamespaceDepthForHost("gitlab.com"); got != 1 {
+		t.Errorf("NamespaceDepthForHost(gitlab.com) = %d, want 1", got)
+	}
+
+	if got := cfg.NamespaceDepthForHost("unknown.com"); got != 1 {
+		t.Errorf("NamespaceDepthForHost(unknown.com) = %d, want 1", got)
+	}
+}
+
+func TestStoreDepthForHost(t *testing.T) {
+	cfg := &Config{
+		Hosts: map[string]HostConfig{
+			"gitlab.com":         {NamespaceDepth: 3, StoreDepth: 1},
+			"gitlab.company.com": {NamespaceDepth: 2},
+			"git.example.com":    {NamespaceDepth: 2, StoreDepth: 5},
+		},
+	}
+
+	tests := map[string]int{
+		"gitlab.com":         1,
+		"gitlab.company.com": 2,
+		"git.example.com":    2,
+		"unknown.com":        1,
+	}
+	for host, want := range tests {
+		if got := cfg.StoreDepthForHost(host); got != want {
+			t.Errorf("StoreDepthForHost(%s) = %d, want %d", host, got, want)
+		}
+	}
+}
+
+func TestLoadInvalidNamespaceRules(t *testing.T) {
+	tests := map[string]string{
+		"missing namespace": "[[namespaces]]\nmatch = \"github.com/acme-*\"\n",
+		"missing pattern":   "[[namespaces]]\nnamespace = \"acme\"\n",
+		"both patterns":     "[[namespaces]]\nmatch = \"a\"\nregex = \"a\"\nnamespace = \"acme\"\n",
+		"bad glob":          "[[namespaces]]\nmatch = \"github.com/[acme\"\nnamespace = \"acme\"\n",
+		"bad regex":         "[[namespaces]]\nregex = \"(acme\"\nnamespace = \"acme\"\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() should reject invalid namespace rule")
+			}
+		})
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	cfg := &Config{
+		Hosts: map[string]HostConfig{
+			"gitlab.corp.example": {Provider: "gitlab", Aliases: []string{"gitlab-ssh.corp.example", "10.1.2.3:8443"}},
+			"github.com":          {Provider: "github"},
+		},
+	}
+
+	tests := map[string]string{
+		"gitlab.corp.example":     "gitlab.corp.example",
+		"gitlab-ssh.corp.example": "gitlab.corp.example",
+		"GitLab-SSH.corp.example": "gitlab.corp.example",
+		"10.1.2.3:8443":           "gitlab.corp.example",
+		"10.1.2.3":                "10.1.2.3",
+		"github.com":              "github.com",
+		"unknown.com":             "unknown.com",
+	}
+	for host, want := range tests {
+		if got := cfg.CanonicalHost(host); got != want {
+			t.Errorf("CanonicalHost(%s) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestLoadInvalidAliases(t *testing.T) {
+	tests := map[string]string{
+		"alias is a host":    "[hosts.\"a.example\"]\naliases = [\"b.example\"]\n[hosts.\"b.example\"]\nprovider = \"gitlab\"\n",
+		"alias of two hosts": "[hosts.\"a.example\"]\naliases = [\"c.example\"]\n[hosts.\"b.example\"]\naliases = [\"C.example\"]\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() should reject conflicting aliases")
+			}
+		})
+	}
+}
+
+func TestChainForHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[defaults]
+backends = ["keychain", "onepassword"]
+write_policy = "primary"
+primary = "onepassword"
+backfill = true
+
+[hosts."gitlab.com"]
+backend = "vault"
+
+[hosts."github.com"]
+backends = ["secretservice", "file"]
+write_policy = "all"
+backfill = false
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	def := cfg.ChainForHost("bitbucket.org")
+	if !slices.Equal(def.Backends, []string{"keychain", "onepassword"}) || def.WritePolicy != "primary" || def.Primary != "onepassword" || !*def.Backfill {
+		t.Errorf("ChainForHost(bitbucket.org) = %+v", def)
+	}
+	if got := cfg.BackendForHost("bitbucket.org"); got != "keychain" {
+		t.Errorf("BackendForHost(bitbucket.org) = %q, want first of chain", got)
+	}
+
+	if got := cfg.BackendsForHost("gitlab.com"); !slices.Equal(got, []string{"vault"}) {
+		t.Errorf("BackendsForHost(gitlab.com) = %v, want [vault]", got)
+	}
+
+	gh := cfg.ChainForHost("github.com")
+	if !slices.Equal(gh.Backends, []string{"secretservice", "file"}) || gh.WritePolicy != "all" || *gh.Backfill {
+		t.Errorf("ChainForHost(github.com) = %+v", gh)
+	}
+}
+
+func TestLoadInvalidChains(t *testing.T) {
+	tests := map[string]string{
+		"unknown policy":       "[defaults]\nbackends = [\"keychain\", \"file\"]\nwrite_policy = \"some\"\n",
+		"primary not in chain": "[defaults]\nbackends = [\"keychain\", \"file\"]\nwrite_policy = \"primary\"\nprimary = \"vault\"\n",
+		"backend and backends": "[hosts.\"gitlab.com\"]\nbackend = \"vault\"\nbackends = [\"keychain\"]\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() should reject invalid backend chain")
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cfg := &Config{Cache: CacheConfig{
+		TTL: "10m",
+		Namespaces: map[string]string{
+			"gitlab.com/org1": "1h",
+			"github.com":      "0",
+		},
+	}}
+
+	tests := map[string]time.Duration{
+		"gitlab.com/org1":       time.Hour,
+		"gitlab.com/org1/group": time.Hour,
+		"gitlab.com/org10":      10 * time.Minute,
+		"github.com/org2":       0,
+		"bitbucket.org/org3":    10 * time.Minute,
+	}
+	for ns, want := range tests {
+		if got := cfg.CacheTTL(ns); got != want {
+			t.Errorf("CacheTTL(%q) = %v, want %v", ns, got, want)
+		}
+	}
+
+	if got := (&Config{}).CacheTTL("gitlab.com/org1"); got != DefaultCacheTTL {
+		t.Errorf("CacheTTL() without config = %v, want %v", got, DefaultCacheTTL)
+	}
+}
+
+func TestLoadInvalidCache(t *testing.T) {
+	tests := map[string]string{
+		"bad ttl":           "[cache]\nttl = \"soon\"\n",
+		"bad namespace ttl": "[cache.namespaces]\n\"gitlab.com/org1\" = \"1 hour\"\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() should reject invalid cache TTL")
+			}
+		})
+	}
+}
+
+func TestBackendNames(t *testing.T) {
+	cfg := &Config{
+		Defaults: DefaultsConfig{Backend: "keychain"},
+		Hosts: map[string]HostConfig{
+			"gitlab.com":    {Backend: "onepassword"},
+			"github.com":    {ChainConfig: ChainConfig{Backends: []string{"keychain", "file"}}},
+			"bitbucket.org": {Provider: "bitbucket"},
+		},
+	}
+
+	want := []string{"keychain", "file", "onepassword"}
+	if got := cfg.BackendNames(); !slices.Equal(got, want) {
+		t.Errorf("BackendNames() = %v, want %v", got, want)
+	}
+}
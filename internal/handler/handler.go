@@ -6,24 +6,58 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"golang.org/x/term"
 
+	"github.com/imcitius/git-credentials-org/internal/cache"
 	"github.com/imcitius/git-credentials-org/internal/config"
 	"github.com/imcitius/git-credentials-org/internal/protocol"
 	"github.com/imcitius/git-credentials-org/internal/provider"
+	"github.com/imcitius/git-credentials-org/internal/remote"
 	"github.com/imcitius/git-credentials-org/internal/resolver"
 	"github.com/imcitius/git-credentials-org/internal/store"
 )
 
+// Version is recorded in the metadata of stored credentials; main sets it
+// to the build version.
+var Version = "dev"
+
+// lastUsedInterval throttles recording when a credential was last used,
+// so get doesn't write to the backend on every git operation.
+const lastUsedInterval = time.Hour
+
 type Handler struct {
 	cfg     *config.Config
 	verbose bool
+
+	// newStore, prompt, inferPath and choose are swapped out in tests.
+	newStore  func(backendName string, cfg *config.Config) (store.CredentialStore, error)
+	prompt    func(prov provider.Provider, namespace string) (*store.Credential, error)
+	inferPath func(matches func(host string) bool, namespace func(path string) string) (path, source string, err error)
+	choose    func(namespace string, choices []importChoice) (int, error)
+
+	// cache is the cache daemon client, nil unless the cache is enabled.
+	cache store.Cache
+
+	// rules are the compiled [[namespaces]] rules.
+	rules []resolver.Rule
 }
 
 func New(cfg *config.Config, verbose bool) *Handler {
-	return &Handler{cfg: cfg, verbose: verbose}
+	h := &Handler{cfg: cfg, verbose: verbose, newStore: store.New, rules: newRules(cfg)}
+	if cfg.Cache.Enabled {
+		h.cache = cache.NewClient(config.ExpandPath(cfg.Cache.Socket))
+	}
+	h.prompt = h.promptForCredentials
+	h.choose = h.chooseOnTerminal
+	h.inferPath = func(matches func(string) bool, namespace func(string) string) (string, string, error) {
+		return remote.InferPath("", matches, namespace)
+	}
+	return h
 }
 
 func (h *Handler) Get(r io.Reader, w io.Writer) error {
@@ -31,45 +65,75 @@ func (h *Handler) Get(r io.Reader, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	host, path := h.normalize(cred)
 
-	namespace := resolver.Resolve(cred.Host, cred.Path)
-	h.log("get: namespace=%s (host=%s, path=%s)", namespace, cred.Host, cred.Path)
+	candidates := h.candidates(host, path)
+	namespace := candidates[0]
+	h.log("get: namespace=%s (host=%s, path=%s)", namespace, host, path)
 
-	backend, err := h.storeForHost(cred.Host)
+	prov, err := h.providerForRequest(host, cred)
 	if err != nil {
 		return err
 	}
+	h.log("get: provider=%s", prov.Name())
 
-	stored, err := backend.Get(namespace)
-	if err != nil && !errors.Is(err, store.ErrNotFound) {
-		return fmt.Errorf("backend %s get: %w", backend.Name(), err)
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return err
+	}
+
+	found, stored, err := h.lookup(backend, candidates)
+	if err != nil {
+		return err
 	}
 
 	if stored != nil {
-		h.log("get: found credentials in %s for %s", backend.Name(), namespace)
-		return protocol.Write(w, &protocol.Credential{
-			Protocol: cred.Protocol,
-			Host:     cred.Host,
-			Username: stored.Username,
-			Password: stored.Password,
-		})
+		h.log("get: found credentials in %s for %s", backend.Name(), found)
+		h.touch(backend, found, stored)
+		return protocol.Write(w, h.response(cred, prov, stored))
 	}
 
 	// No stored credentials -- prompt the user but do NOT persist yet.
 	// Git will call "store" after verifying auth succeeded, or "erase" on failure.
 	h.log("get: no credentials found, prompting user (will persist on 'store' callback)")
-	prov := h.providerForHost(cred.Host)
-	newCred, err := h.promptForCredentials(prov, namespace)
+	newCred, err := h.prompt(prov, namespace)
 	if err != nil {
 		return fmt.Errorf("prompting for credentials: %w", err)
 	}
 
-	return protocol.Write(w, &protocol.Credential{
-		Protocol: cred.Protocol,
-		Host:     cred.Host,
-		Username: newCred.Username,
-		Password: newCred.Password,
-	})
+	return protocol.Write(w, h.response(cred, prov, newCred))
+}
+
+// response builds the reply to a get request. When git announced the
+// authtype capability and the secret was stored pre-encoded or the
+// provider accepts it as a bearer token, it is returned as such; otherwise it falls back to
+// Basic auth with username and password for older git versions.
+func (h *Handler) response(req *protocol.Credential, prov provider.Provider, c *store.Credential) *protocol.Credential {
+	resp := &protocol.Credential{
+		Protocol:          req.Protocol,
+		Host:              req.Host,
+		PasswordExpiryUTC: c.PasswordExpiryUTC,
+		OAuthRefreshToken: c.OAuthRefreshToken,
+	}
+
+	authType := c.AuthType
+	if authType == "" && prov.SupportsBearer(c.Password, c.OAuthRefreshToken != "") {
+		authType = "Bearer"
+	}
+	if authType != "" && req.HasCapability(protocol.CapabilityAuthType) {
+		h.log("get: answering with authtype=%s", authType)
+		resp.Capabilities = []string{protocol.CapabilityAuthType}
+		resp.AuthType = authType
+		resp.Credential = c.Password
+		return resp
+	}
+
+	resp.Username = c.Username
+	if resp.Username == "" {
+		resp.Username = prov.DefaultUsername()
+	}
+	resp.Password = c.Password
+	return resp
 }
 
 func (h *Handler) Store(r io.Reader) error {
@@ -77,23 +141,64 @@ func (h *Handler) Store(r io.Reader) error {
 	if err != nil {
 		return err
 	}
+	host, path := h.normalize(cred)
 
-	if cred.Username == "" || cred.Password == "" {
+	secret := &store.Credential{
+		Username:          cred.Username,
+		Password:          cred.Password,
+		PasswordExpiryUTC: cred.PasswordExpiryUTC,
+		OAuthRefreshToken: cred.OAuthRefreshToken,
+	}
+
+	// Pre-encoded credentials arrive as authtype/credential instead of
+	// username/password when git and the helper that produced them both
+	// support it.
+	if cred.AuthType != "" && cred.Credential != "" {
+		secret.AuthType = cred.AuthType
+		secret.Password = cred.Credential
+	} else if cred.Username == "" || cred.Password == "" {
 		return nil
 	}
 
-	namespace := resolver.Resolve(cred.Host, cred.Path)
-	h.log("store: upsert for namespace=%s", namespace)
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return err
+	}
 
-	backend, err := h.storeForHost(cred.Host)
+	// Update the credential where it already lives, so a group-level token
+	// found by get isn't copied down into every subgroup. Otherwise save it
+	// at the configured store depth.
+	namespace, existing, err := h.holder(backend, h.candidates(host, path), secret.Password)
 	if err != nil {
 		return err
 	}
+	if existing != nil {
+		// git stores after every successful get; keep the metadata of the
+		// credential and skip rewriting it if nothing changed.
+		keepMetadata(secret, existing)
+		if *secret == *existing {
+			h.log("store: %s unchanged", namespace)
+			return nil
+		}
+	} else {
+		now := time.Now().Unix()
+		secret.CreatedAt = now
+		secret.LastUsedAt = now
+		secret.Provider = provider.ForHost(host, h.cfg.ProviderForHost(host)).Name()
+		secret.Origin = strings.TrimSuffix(host+"/"+path, "/")
+		secret.HelperVersion = Version
+		namespace = h.storeNamespace(host, path)
+	}
+	h.log("store: upsert for namespace=%s", namespace)
 
-	return backend.Store(namespace, &store.Credential{
-		Username: cred.Username,
-		Password: cred.Password,
-	})
+	if err := backend.Store(namespace, secret); err != nil {
+		if errors.Is(err, store.ErrReadOnly) {
+			h.log("store: %s is read-only, skipping", backend.Name())
+			return nil
+		}
+		return err
+	}
+	return nil
 }
 
 func (h *Handler) Erase(r io.Reader) error {
@@ -101,26 +206,248 @@ func (h *Handler) Erase(r io.Reader) error {
 	if err != nil {
 		return err
 	}
+	host, path := h.normalize(cred)
 
-	namespace := resolver.Resolve(cred.Host, cred.Path)
-	h.log("erase: removing namespace=%s", namespace)
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return err
+	}
+
+	// Erase the level holding the rejected password; without a password,
+	// erase whichever level get would have returned.
+	rejected := cred.Password
+	if cred.Credential != "" {
+		rejected = cred.Credential
+	}
 
-	backend, err := h.storeForHost(cred.Host)
+	candidates := h.candidates(host, path)
+	namespace, _, err := h.holder(backend, candidates, rejected)
 	if err != nil {
 		return err
 	}
+	if namespace == "" && rejected == "" {
+		namespace, _, err = h.lookup(backend, candidates)
+		if err != nil {
+			return err
+		}
+	}
+	if namespace == "" {
+		namespace = candidates[0]
+	}
+	h.log("erase: removing namespace=%s", namespace)
+
+	if err := backend.Erase(namespace); err != nil {
+		if errors.Is(err, store.ErrReadOnly) {
+			h.log("erase: %s is read-only, skipping", backend.Name())
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// normalize returns the request's host and path in canonical form: see
+// resolver.Normalize, with host aliases mapped to the configured host.
+// Without a path (credential.useHttpPath off) it is inferred from the
+// current repository's remotes, so orgs don't collapse into one host-wide
+// credential.
+func (h *Handler) normalize(cred *protocol.Credential) (string, string) {
+	host, path := resolver.Normalize(cred.Protocol, cred.Host, cred.Path)
+
+	canonical := h.cfg.CanonicalHost(host)
+	if canonical != host {
+		h.log("host %s is an alias of %s", host, canonical)
+	}
+
+	if path == "" {
+		path = h.inferRequestPath(cred.Protocol, canonical)
+	}
+
+	return canonical, path
+}
+
+func (h *Handler) inferRequestPath(protocol, host string) string {
+	matches := func(remoteHost string) bool {
+		rh, _ := resolver.Normalize(protocol, remoteHost, "")
+		return h.cfg.CanonicalHost(rh) == host
+	}
+	namespace := func(remotePath string) string {
+		_, p := resolver.Normalize(protocol, host, remotePath)
+		return h.candidates(host, p)[0]
+	}
+
+	path, source, err := h.inferPath(matches, namespace)
+	if err != nil {
+		h.log("no path given and remote inference failed: %v", err)
+		return ""
+	}
+	if path == "" {
+		h.log("no path given and no remote for %s found; using host-wide namespace", host)
+		return ""
+	}
+
+	_, path = resolver.Normalize(protocol, host, path)
+	h.log("no path given, inferred %q from %s", path, source)
+	return path
+}
+
+// candidates returns the namespaces for a request, most specific first.
+// A matching [[namespaces]] rule yields exactly one namespace.
+func (h *Handler) candidates(host, path string) []string {
+	if ns, ok := h.matchRule(host, path); ok {
+		return []string{ns}
+	}
+	depth := h.cfg.NamespaceDepthForHost(host)
+	return resolver.Candidates(host, path, depth)
+}
+
+// storeNamespace returns the namespace a new credential is saved under.
+func (h *Handler) storeNamespace(host, path string) string {
+	if ns, ok := h.matchRule(host, path); ok {
+		return ns
+	}
+	depth := h.cfg.StoreDepthForHost(host)
+	return resolver.ResolveDepth(host, path, depth)
+}
 
-	return backend.Erase(namespace)
+func (h *Handler) matchRule(host, path string) (string, bool) {
+	return resolver.Match(h.rules, host, path)
 }
 
+// newRules compiles the [[namespaces]] rules of cfg. config.Load has
+// checked their patterns; a regex that doesn't compile is left out, as it
+// could never match.
+func newRules(cfg *config.Config) []resolver.Rule {
+	rules := make([]resolver.Rule, 0, len(cfg.Namespaces))
+	for _, r := range cfg.Namespaces {
+		rule := resolver.Rule{Glob: r.Match, Namespace: r.Namespace}
+		if r.Regex != "" {
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				continue
+			}
+			rule.Regex = re
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// lookup returns the first namespace in candidates with a usable
+// credential, skipping expired ones. It returns a nil credential if no
+// level has one.
+func (h *Handler) lookup(backend store.CredentialStore, candidates []string) (string, *store.Credential, error) {
+	for _, ns := range candidates {
+		stored, err := backend.Get(ns)
+		if errors.Is(err, store.ErrNotFound) {
+			h.log("lookup: nothing in %s for %s", backend.Name(), ns)
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("backend %s get: %w", backend.Name(), err)
+		}
+
+		if stored.Expired(time.Now()) {
+			h.log("lookup: credentials in %s for %s expired at %s, ignoring",
+				backend.Name(), ns, time.Unix(stored.PasswordExpiryUTC, 0).UTC().Format(time.RFC3339))
+			continue
+		}
+
+		return ns, stored, nil
+	}
+	return "", nil, nil
+}
+
+// holder returns the first namespace in candidates whose stored secret is
+// password and the credential stored there, or empty string if none is.
+func (h *Handler) holder(backend store.CredentialStore, candidates []string, password string) (string, *store.Credential, error) {
+	if password == "" {
+		return "", nil, nil
+	}
+	for _, ns := range candidates {
+		stored, err := backend.Get(ns)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("backend %s get: %w", backend.Name(), err)
+		}
+		if stored.Password == password {
+			return ns, stored, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// touch records that the credential stored at namespace was used, at most
+// once per lastUsedInterval, in the backends holding it: a chain's write
+// policy must not copy it elsewhere. Failures only cost the timestamp.
+func (h *Handler) touch(backend store.CredentialStore, namespace string, stored *store.Credential) {
+	now := time.Now()
+	if now.Sub(time.Unix(stored.LastUsedAt, 0)) < lastUsedInterval {
+		return
+	}
+
+	used := *stored
+	used.LastUsedAt = now.Unix()
+	write := backend.Store
+	if u, ok := backend.(store.Updater); ok {
+		write = u.Update
+	}
+	if err := write(namespace, &used); err != nil && !errors.Is(err, store.ErrReadOnly) {
+		h.log("get: recording last use in %s: %v", backend.Name(), err)
+	}
+}
+
+// keepMetadata copies the metadata of the stored credential into cred,
+// which git handed back to store.
+func keepMetadata(cred, stored *store.Credential) {
+	cred.CreatedAt = stored.CreatedAt
+	cred.LastUsedAt = stored.LastUsedAt
+	cred.Provider = stored.Provider
+	cred.Origin = stored.Origin
+	cred.HelperVersion = stored.HelperVersion
+}
+
+// storeForHost returns the host's backend, behind the cache daemon when
+// it is enabled.
 func (h *Handler) storeForHost(host string) (store.CredentialStore, error) {
-	backendName := h.cfg.BackendForHost(host)
-	return store.New(backendName, h.cfg)
+	backend, err := h.backendForHost(host)
+	if err != nil || h.cache == nil {
+		return backend, err
+	}
+	prefix := strings.Join(h.cfg.BackendsForHost(host), ",")
+	return store.NewCachedStore(backend, h.cache, prefix, h.cfg.CacheTTL), nil
 }
 
-func (h *Handler) providerForHost(host string) provider.Provider {
+// backendForHost returns the host's backend, or a chain when it has
+// several.
+func (h *Handler) backendForHost(host string) (store.CredentialStore, error) {
+	chain := h.cfg.ChainForHost(host)
+	if len(chain.Backends) == 1 {
+		return h.newStore(chain.Backends[0], h.cfg)
+	}
+
+	stores := make([]store.CredentialStore, len(chain.Backends))
+	for i, name := range chain.Backends {
+		s, err := h.newStore(name, h.cfg)
+		if err != nil {
+			return nil, err
+		}
+		stores[i] = s
+	}
+	h.log("using backends %v (write policy %q)", chain.Backends, chain.WritePolicy)
+
+	backfill := chain.Backfill != nil && *chain.Backfill
+	return store.NewChain(stores, chain.WritePolicy, slices.Index(chain.Backends, chain.Primary), backfill)
+}
+
+func (h *Handler) providerForRequest(host string, cred *protocol.Credential) (provider.Provider, error) {
 	configured := h.cfg.ProviderForHost(host)
-	return provider.ForHost(host, configured)
+	for _, c := range cred.WWWAuth {
+		h.log("get: challenge %q", c)
+	}
+	return provider.ForRequest(host, configured, cred.WWWAuth)
 }
 
 func (h *Handler) promptForCredentials(prov provider.Provider, namespace string) (*store.Credential, error) {
@@ -3,10 +3,13 @@ package handler
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/provider"
 	"github.com/imcitius/git-credentials-org/internal/store"
 )
 
@@ -38,6 +41,33 @@ func (m *mockStore) Erase(namespace string) error {
 	return nil
 }
 
+// newTestHandler returns a handler wired to the given mock store, with
+// prompting answered by promptCred (or failing if nil).
+func newTestHandler(cfg *config.Config, mock *mockStore, promptCred *store.Credential) *Handler {
+	h := New(cfg, false)
+	h.newStore = func(string, *config.Config) (store.CredentialStore, error) {
+		return mock, nil
+	}
+	h.prompt = func(provider.Provider, string) (*store.Credential, error) {
+		if promptCred == nil {
+			return nil, errors.New("unexpected prompt")
+		}
+		return promptCred, nil
+	}
+	h.inferPath = func(func(string) bool, func(string) string) (string, string, error) {
+		return "", "", nil
+	}
+	return h
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Defaults: config.DefaultsConfig{Backend: "mock"},
+		Hosts:    make(map[string]config.HostConfig),
+		Backends: make(map[string]config.BackendConfig),
+	}
+}
+
 // testHandler creates a handler that uses a mock store via a custom store factory.
 // Since we can't inject the store directly through the public API, we test
 // the store and protocol layers independently and do integration-style tests
@@ -103,6 +133,27 @@ func TestHandlerGetWithExistingCredentials(t *testing.T) {
 	}
 }
 
+func TestHandlerGetRefusesMismatchedChallenge(t *testing.T) {
+	cfg := &config.Config{
+		Defaults: config.DefaultsConfig{Backend: "keychain"},
+		Hosts:    map[string]config.HostConfig{"git.corp.example": {Provider: "github"}},
+		Backends: make(map[string]config.BackendConfig),
+	}
+
+	h := New(cfg, false)
+
+	input := "protocol=https\nhost=git.corp.example\npath=org1/repo.git\nwwwauth[]=Basic realm=\"GitLab\"\n\n"
+	var output bytes.Buffer
+
+	err := h.Get(strings.NewReader(input), &output)
+	if !errors.Is(err, provider.ErrChallengeMismatch) {
+		t.Fatalf("Get() error = %v, want %v", err, provider.ErrChallengeMismatch)
+	}
+	if output.Len() != 0 {
+		t.Errorf("Get() wrote output on refusal: %q", output.String())
+	}
+}
+
 func TestHandlerStoreOperation(t *testing.T) {
 	cfg := &config.Config{
 		Defaults: config.DefaultsConfig{Backend: "keychain"},
@@ -135,3 +186,578 @@ func TestHandlerEraseOperation(t *testing.T) {
 		t.Logf("Expected error in non-keychain env: %v", err)
 	}
 }
+
+func TestHandlerGetReturnsExpiry(t *testing.T) {
+	mock := newMockStore()
+	expiry := time.Now().Add(time.Hour).Unix()
+	mock.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "live", PasswordExpiryUTC: expiry}
+
+	h := newTestHandler(testConfig(), mock, nil)
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	want := fmt.Sprintf("password_expiry_utc=%d\n", expiry)
+	if !strings.Contains(output.String(), "password=live\n") || !strings.Contains(output.String(), want) {
+		t.Errorf("Get() output = %q, want password and %q", output.String(), want)
+	}
+}
+
+func TestHandlerGetSkipsExpired(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org1"] = &store.Credential{
+		Username:          "oauth2",
+		Password:          "dead",
+		PasswordExpiryUTC: time.Now().Add(-time.Minute).Unix(),
+	}
+
+	h := newTestHandler(testConfig(), mock, &store.Credential{Username: "oauth2", Password: "fresh"})
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if strings.Contains(output.String(), "dead") {
+		t.Errorf("Get() returned expired credential: %q", output.String())
+	}
+	if !strings.Contains(output.String(), "password=fresh\n") {
+		t.Errorf("Get() output = %q, want prompted credential", output.String())
+	}
+}
+
+func TestHandlerStorePersistsExpiry(t *testing.T) {
+	mock := newMockStore()
+	h := newTestHandler(testConfig(), mock, nil)
+
+	input := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\nusername=oauth2\npassword=tok\npassword_expiry_utc=2000000000\n\n"
+	if err := h.Store(strings.NewReader(input)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got := mock.creds["gitlab.com/org1"]
+	if got == nil || got.PasswordExpiryUTC != 2000000000 {
+		t.Errorf("stored credential = %+v, want expiry 2000000000", got)
+	}
+}
+
+func TestHandlerRefreshTokenRoundTrip(t *testing.T) {
+	mock := newMockStore()
+	h := newTestHandler(testConfig(), mock, nil)
+
+	input := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\nusername=oauth2\npassword=tok\noauth_refresh_token=refresh-abc\n\n"
+	if err := h.Store(strings.NewReader(input)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	var output bytes.Buffer
+	input = "protocol=https\nhost=gitlab.com\npath=org1/other.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if !strings.Contains(output.String(), "oauth_refresh_token=refresh-abc\n") {
+		t.Errorf("Get() output = %q, want refresh token echoed", output.String())
+	}
+}
+
+func TestHandlerGetBearerWithCapability(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["github.com/org1"] = &store.Credential{Username: "x-access-token", Password: "github_pat_abc"}
+
+	h := newTestHandler(testConfig(), mock, nil)
+
+	var output bytes.Buffer
+	input := "capability[]=authtype\nprotocol=https\nhost=github.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	out := output.String()
+	for _, want := range []string{"capability[]=authtype\n", "authtype=Bearer\n", "credential=github_pat_abc\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Get() output missing %q, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "username=") || strings.Contains(out, "password=") {
+		t.Errorf("Get() output should not include username/password, got %q", out)
+	}
+}
+
+func TestHandlerGetBearerFallsBackToBasic(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["github.com/org1"] = &store.Credential{Password: "github_pat_abc", AuthType: "Bearer"}
+
+	h := newTestHandler(testConfig(), mock, nil)
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=github.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	out := output.String()
+	if !strings.Contains(out, "username=x-access-token\n") || !strings.Contains(out, "password=github_pat_abc\n") {
+		t.Errorf("Get() output = %q, want Basic credentials with provider username", out)
+	}
+	if strings.Contains(out, "authtype=") {
+		t.Errorf("Get() output must not use authtype without the capability, got %q", out)
+	}
+}
+
+func TestHandlerStorePreEncoded(t *testing.T) {
+	mock := newMockStore()
+	h := newTestHandler(testConfig(), mock, nil)
+
+	input := "capability[]=authtype\nprotocol=https\nhost=gitlab.com\npath=org1/repo.git\nauthtype=Bearer\ncredential=oauth-tok\n\n"
+	if err := h.Store(strings.NewReader(input)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got := mock.creds["gitlab.com/org1"]
+	if got == nil || got.AuthType != "Bearer" || got.Password != "oauth-tok" {
+		t.Errorf("stored credential = %+v, want Bearer oauth-tok", got)
+	}
+}
+
+func TestHandlerStoreUsesNamespaceDepth(t *testing.T) {
+	mock := newMockStore()
+	cfg := testConfig()
+	cfg.Hosts["gitlab.company.com"] = config.HostConfig{Provider: "gitlab", NamespaceDepth: 2}
+	h := newTestHandler(cfg, mock, nil)
+
+	for _, team := range []string{"team-a", "team-b"} {
+		input := "protocol=https\nhost=gitlab.company.com\npath=company/" + team + "/repo.git\nusername=oauth2\npassword=" + team + "-token\n\n"
+		if err := h.Store(strings.NewReader(input)); err != nil {
+			t.Fatalf("Store(%s) error = %v", team, err)
+		}
+	}
+
+	for _, team := range []string{"team-a", "team-b"} {
+		got := mock.creds["gitlab.company.com/company/"+team]
+		if got == nil || got.Password != team+"-token" {
+			t.Errorf("namespace for %s = %+v, want %s-token", team, got, team)
+		}
+	}
+}
+
+func TestHandlerGetWalksUpNamespaces(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org"] = &store.Credential{Username: "oauth2", Password: "group-token"}
+
+	cfg := testConfig()
+	cfg.Hosts["gitlab.com"] = config.HostConfig{Provider: "gitlab", NamespaceDepth: 3}
+	h := newTestHandler(cfg, mock, nil)
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=gitlab.com\npath=org/sub/subsub/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if !strings.Contains(output.String(), "password=group-token\n") {
+		t.Errorf("Get() output = %q, want group-level token", output.String())
+	}
+}
+
+func TestHandlerGetPrefersDeepestNamespace(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org"] = &store.Credential{Username: "oauth2", Password: "group-token"}
+	mock.creds["gitlab.com/org/sub"] = &store.Credential{Username: "oauth2", Password: "sub-token"}
+
+	cfg := testConfig()
+	cfg.Hosts["gitlab.com"] = config.HostConfig{Provider: "gitlab", NamespaceDepth: 3}
+	h := newTestHandler(cfg, mock, nil)
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=gitlab.com\npath=org/sub/subsub/repo.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if !strings.Contains(output.String(), "password=sub-token\n") {
+		t.Errorf("Get() output = %q, want subgroup token", output.String())
+	}
+}
+
+func TestHandlerStoreLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]string
+		host     config.HostConfig
+		wantNS   string
+	}{
+		{
+			name:   "new credential at namespace depth",
+			host:   config.HostConfig{NamespaceDepth: 3},
+			wantNS: "gitlab.com/org/sub/subsub",
+		},
+		{
+			name:   "new credential at configured store depth",
+			host:   config.HostConfig{NamespaceDepth: 3, StoreDepth: 1},
+			wantNS: "gitlab.com/org",
+		},
+		{
+			name:     "existing credential updated where it lives",
+			existing: map[string]string{"gitlab.com/org": "tok"},
+			host:     config.HostConfig{NamespaceDepth: 3},
+			wantNS:   "gitlab.com/org",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockStore()
+			for ns, pw := range tt.existing {
+				mock.creds[ns] = &store.Credential{Username: "oauth2", Password: pw}
+			}
+
+			cfg := testConfig()
+			cfg.Hosts["gitlab.com"] = tt.host
+			h := newTestHandler(cfg, mock, nil)
+
+			input := "protocol=https\nhost=gitlab.com\npath=org/sub/subsub/repo.git\nusername=oauth2\npassword=tok\npassword_expiry_utc=2000000000\n\n"
+			if err := h.Store(strings.NewReader(input)); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+
+			got := mock.creds[tt.wantNS]
+			if got == nil || got.PasswordExpiryUTC != 2000000000 {
+				t.Errorf("credential at %s = %+v, want freshly stored", tt.wantNS, got)
+			}
+			if len(mock.creds) != 1 {
+				t.Errorf("store wrote %d namespaces, want 1", len(mock.creds))
+			}
+		})
+	}
+}
+
+func TestHandlerEraseRejectedLevel(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org"] = &store.Credential{Username: "oauth2", Password: "bad"}
+	mock.creds["gitlab.com/org/sub"] = &store.Credential{Username: "oauth2", Password: "good"}
+
+	cfg := testConfig()
+	cfg.Hosts["gitlab.com"] = config.HostConfig{NamespaceDepth: 2}
+	h := newTestHandler(cfg, mock, nil)
+
+	input := "protocol=https\nhost=gitlab.com\npath=org/sub/repo.git\nusername=oauth2\npassword=bad\n\n"
+	if err := h.Erase(strings.NewReader(input)); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+
+	if _, ok := mock.creds["gitlab.com/org"]; ok {
+		t.Error("Erase() kept the rejected group-level credential")
+	}
+	if _, ok := mock.creds["gitlab.com/org/sub"]; !ok {
+		t.Error("Erase() removed an unrelated credential")
+	}
+}
+
+func TestHandlerNamespaceRules(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["acme"] = &store.Credential{Username: "x-access-token", Password: "acme-token"}
+
+	cfg := testConfig()
+	cfg.Namespaces = []config.NamespaceRule{{Match: "github.com/acme-*", Namespace: "acme"}}
+	h := newTestHandler(cfg, mock, nil)
+
+	for _, org := range []string{"acme-web", "acme-infra"} {
+		var output bytes.Buffer
+		input := "protocol=https\nhost=github.com\npath=" + org + "/repo.git\n\n"
+		if err := h.Get(strings.NewReader(input), &output); err != nil {
+			t.Fatalf("Get(%s) error = %v", org, err)
+		}
+		if !strings.Contains(output.String(), "password=acme-token\n") {
+			t.Errorf("Get(%s) output = %q, want shared acme token", org, output.String())
+		}
+	}
+
+	input := "protocol=https\nhost=github.com\npath=acme-new/repo.git\nusername=x-access-token\npassword=rotated\n\n"
+	if err := h.Store(strings.NewReader(input)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if got := mock.creds["acme"]; got == nil || got.Password != "rotated" {
+		t.Errorf("Store() credential for acme = %+v, want rotated", got)
+	}
+	if len(mock.creds) != 1 {
+		t.Errorf("Store() wrote %d namespaces, want 1", len(mock.creds))
+	}
+}
+
+func TestHandlerHostAliases(t *testing.T) {
+	mock := newMockStore()
+	cfg := testConfig()
+	cfg.Hosts["gitlab.corp.example"] = config.HostConfig{
+		Provider: "gitlab",
+		Backend:  "corp",
+		Aliases:  []string{"gitlab-ssh.corp.example", "10.1.2.3:8443"},
+	}
+
+	var backends []string
+	h := newTestHandler(cfg, mock, nil)
+	h.newStore = func(name string, _ *config.Config) (store.CredentialStore, error) {
+		backends = append(backends, name)
+		return mock, nil
+	}
+
+	input := "protocol=https\nhost=gitlab-ssh.corp.example\npath=team/repo.git\nusername=oauth2\npassword=corp-token\n\n"
+	if err := h.Store(strings.NewReader(input)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if _, ok := mock.creds["gitlab.corp.example/team"]; !ok {
+		t.Fatalf("Store() namespaces = %v, want gitlab.corp.example/team", mock.creds)
+	}
+
+	var output bytes.Buffer
+	input = "protocol=https\nhost=10.1.2.3:8443\npath=team/other.git\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "password=corp-token\n") {
+		t.Errorf("Get() output = %q, want token stored via alias", output.String())
+	}
+	if !strings.Contains(output.String(), "host=10.1.2.3:8443\n") {
+		t.Errorf("Get() output = %q, want original host echoed", output.String())
+	}
+
+	for _, b := range backends {
+		if b != "corp" {
+			t.Errorf("alias used backend %q, want corp", b)
+		}
+	}
+}
+
+func TestHandlerGetNormalizesURL(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "tok"}
+
+	h := newTestHandler(testConfig(), mock, nil)
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=GitLab.com:443\npath=//org1//repo.git/\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "password=tok\n") {
+		t.Errorf("Get() output = %q, want credential for gitlab.com/org1", output.String())
+	}
+}
+
+func TestHandlerInfersMissingPath(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["github.com/acme"] = &store.Credential{Username: "x-access-token", Password: "acme-token"}
+	mock.creds["github.com"] = &store.Credential{Username: "x-access-token", Password: "host-token"}
+
+	cfg := testConfig()
+	cfg.Hosts["github.com"] = config.HostConfig{Aliases: []string{"ssh.github.com"}}
+	h := newTestHandler(cfg, mock, nil)
+	h.inferPath = func(matches func(string) bool, namespace func(string) string) (string, string, error) {
+		if matches("gitlab.com") {
+			t.Error("matcher accepted a remote on another host")
+		}
+		if ns := namespace("/acme/web.git"); ns != "github.com/acme" {
+			t.Errorf("namespace(acme/web.git) = %q, want github.com/acme", ns)
+		}
+		if !matches("ssh.github.com") {
+			return "", "", nil
+		}
+		return "acme/web.git", "remote \"origin\" in test", nil
+	}
+
+	var output bytes.Buffer
+	input := "protocol=https\nhost=github.com\n\n"
+	if err := h.Get(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !strings.Contains(output.String(), "password=acme-token\n") {
+		t.Errorf("Get() output = %q, want org credential from inferred path", output.String())
+	}
+}
+
+// readOnlyStore is a mockStore refusing writes like a read-only backend.
+type readOnlyStore struct{ *mockStore }
+
+func (readOnlyStore) Store(string, *store.Credential) error { return store.ErrReadOnly }
+func (readOnlyStore) Erase(string) error                    { return store.ErrReadOnly }
+
+func TestHandlerReadOnlyBackend(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-team"}
+
+	h := newTestHandler(testConfig(), mock, nil)
+	h.newStore = func(string, *config.Config) (store.CredentialStore, error) {
+		return readOnlyStore{mock}, nil
+	}
+
+	if err := h.Store(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org1/repo.git\nusername=oauth2\npassword=glpat-new\n\n")); err != nil {
+		t.Errorf("Store() error = %v, want read-only backend skipped", err)
+	}
+	if err := h.Erase(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org1/repo.git\npassword=glpat-team\n\n")); err != nil {
+		t.Errorf("Erase() error = %v, want read-only backend skipped", err)
+	}
+	if got := mock.creds["gitlab.com/org1"].Password; got != "glpat-team" {
+		t.Errorf("password = %q, want unchanged", got)
+	}
+}
+
+func TestHandlerBackendChain(t *testing.T) {
+	keychain, op := newMockStore(), newMockStore()
+	keychain.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-legacy"}
+
+	cfg := testConfig()
+	backfill := true
+	cfg.Defaults.ChainConfig = config.ChainConfig{
+		Backends:    []string{"onepassword", "keychain"},
+		WritePolicy: "primary",
+		Primary:     "onepassword",
+		Backfill:    &backfill,
+	}
+	h := newTestHandler(cfg, nil, nil)
+	h.newStore = func(name string, _ *config.Config) (store.CredentialStore, error) {
+		return map[string]*mockStore{"keychain": keychain, "onepassword": op}[name], nil
+	}
+
+	var out bytes.Buffer
+	if err := h.Get(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"), &out); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "password=glpat-legacy") {
+		t.Errorf("Get() output = %q, want credential from the later backend", out.String())
+	}
+	if op.creds["gitlab.com/org1"] == nil {
+		t.Error("Get() didn't back-fill the first backend")
+	}
+
+	if err := h.Store(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org2/repo.git\nusername=oauth2\npassword=glpat-new\n\n")); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if op.creds["gitlab.com/org2"] == nil || keychain.creds["gitlab.com/org2"] != nil {
+		t.Errorf("Store() wrote to keychain=%v onepassword=%v, want primary only", keychain.creds["gitlab.com/org2"], op.creds["gitlab.com/org2"])
+	}
+}
+
+func TestHandlerBackendChainLastUse(t *testing.T) {
+	for _, policy := range []string{"first", "all"} {
+		keychain, op := newMockStore(), newMockStore()
+		keychain.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-legacy"}
+
+		cfg := testConfig()
+		backfill := false
+		cfg.Defaults.ChainConfig = config.ChainConfig{
+			Backends:    []string{"onepassword", "keychain"},
+			WritePolicy: policy,
+			Backfill:    &backfill,
+		}
+		h := newTestHandler(cfg, nil, nil)
+		h.newStore = func(name string, _ *config.Config) (store.CredentialStore, error) {
+			return map[string]*mockStore{"keychain": keychain, "onepassword": op}[name], nil
+		}
+		h.cache = make(mapCache)
+
+		if err := h.Get(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"), &bytes.Buffer{}); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if op.creds["gitlab.com/org1"] != nil {
+			t.Errorf("policy %s: recording last use copied the credential into the first backend", policy)
+		}
+		if keychain.creds["gitlab.com/org1"].LastUsedAt == 0 {
+			t.Errorf("policy %s: last use not recorded where the credential is held", policy)
+		}
+	}
+}
+
+// mapCache is an in-memory store.Cache.
+type mapCache map[string]*store.Credential
+
+func (m mapCache) Get(key string) (*store.Credential, bool, error) {
+	cred, ok := m[key]
+	return cred, ok, nil
+}
+
+func (m mapCache) Put(key string, cred *store.Credential, _ time.Duration) error {
+	m[key] = cred
+	return nil
+}
+
+func (m mapCache) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestHandlerCache(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-abc"}
+	h := newTestHandler(testConfig(), mock, nil)
+	cache := make(mapCache)
+	h.cache = cache
+
+	req := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(req), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cache["mock:gitlab.com/org1"] == nil {
+		t.Fatalf("Get() didn't cache the credential, cache = %v", cache)
+	}
+
+	// A rejected credential must not be served from the cache.
+	if err := h.Erase(strings.NewReader("protocol=https\nhost=gitlab.com\npath=org1/repo.git\nusername=oauth2\npassword=glpat-abc\n\n")); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, ok := cache["mock:gitlab.com/org1"]; ok {
+		t.Error("Erase() left the credential cached")
+	}
+}
+
+func TestHandlerCredentialMetadata(t *testing.T) {
+	mock := newMockStore()
+	cfg := testConfig()
+	cfg.Hosts["gitlab.com"] = config.HostConfig{Provider: "gitlab"}
+	h := newTestHandler(cfg, mock, nil)
+	Version = "1.4.0"
+	defer func() { Version = "dev" }()
+
+	storeReq := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\nusername=oauth2\npassword=glpat-abc\n\n"
+	if err := h.Store(strings.NewReader(storeReq)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	stored := mock.creds["gitlab.com/org1"]
+	if stored == nil || stored.CreatedAt == 0 || stored.Provider != "gitlab" ||
+		stored.Origin != "gitlab.com/org1/repo" || stored.HelperVersion != "1.4.0" {
+		t.Fatalf("Store() saved %+v, want metadata", stored)
+	}
+
+	// A recent use isn't recorded again.
+	used := stored.LastUsedAt
+	req := "protocol=https\nhost=gitlab.com\npath=org1/repo.git\n\n"
+	if err := h.Get(strings.NewReader(req), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if mock.creds["gitlab.com/org1"].LastUsedAt != used {
+		t.Error("Get() recorded last use within the throttle interval")
+	}
+
+	stale := *stored
+	stale.LastUsedAt = time.Now().Add(-2 * time.Hour).Unix()
+	mock.creds["gitlab.com/org1"] = &stale
+	if err := h.Get(strings.NewReader(req), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	touched := mock.creds["gitlab.com/org1"]
+	if touched.LastUsedAt <= stale.LastUsedAt || touched.CreatedAt != stored.CreatedAt {
+		t.Errorf("Get() left %+v, want last use recorded", touched)
+	}
+
+	// git storing the same credential again keeps its metadata.
+	Version = "1.5.0"
+	if err := h.Store(strings.NewReader(storeReq)); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if got := mock.creds["gitlab.com/org1"]; got != touched {
+		t.Errorf("Store() rewrote an unchanged credential: %+v", got)
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/importer"
+	"github.com/imcitius/git-credentials-org/internal/provider"
+	"github.com/imcitius/git-credentials-org/internal/resolver"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// Conflict strategies of Import, for namespaces with several different
+// secrets (among the imported entries and what is already stored).
+const (
+	ImportInteractive = "interactive" // ask on the terminal
+	ImportSkip        = "skip"        // import nothing for the namespace
+	ImportFirst       = "first"       // keep what is stored, else the first entry
+	ImportLast        = "last"        // take the last entry
+)
+
+// Outcomes of importing a namespace.
+const (
+	ImportImported    = "imported"
+	ImportUnchanged   = "unchanged"
+	ImportKept        = "kept"
+	ImportSkipped     = "skipped"
+	ImportFailed      = "failed"
+	ImportWouldImport = "would import"
+)
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	Strategy string
+	DryRun   bool
+}
+
+// ImportResult is the outcome of importing one namespace.
+type ImportResult struct {
+	Namespace string
+	Outcome   string
+	Detail    string
+}
+
+// importChoice is one of the secrets a namespace could get.
+type importChoice struct {
+	origin string // where it comes from, for the report and prompt
+	host   string
+	path   string
+	cred   store.Credential
+	stored bool
+}
+
+// importGroup collects the distinct secrets found for a namespace.
+type importGroup struct {
+	namespace string
+	host      string
+	choices   []importChoice
+}
+
+// Import stores credentials read from other helpers. Each URL is mapped to
+// a namespace as a git store request for it would be, and saved in that
+// host's backend.
+func (h *Handler) Import(entries []importer.Entry, opts ImportOptions) ([]ImportResult, error) {
+	switch opts.Strategy {
+	case "":
+		opts.Strategy = ImportInteractive
+	case ImportInteractive, ImportSkip, ImportFirst, ImportLast:
+	default:
+		return nil, fmt.Errorf("unknown conflict strategy %q (want interactive, skip, first or last)", opts.Strategy)
+	}
+
+	var results []ImportResult
+	for _, g := range h.groupImports(entries) {
+		outcome, detail := h.importGroup(g, opts)
+		h.log("import: %s %s %s", g.namespace, outcome, detail)
+		results = append(results, ImportResult{Namespace: g.namespace, Outcome: outcome, Detail: detail})
+	}
+	return results, nil
+}
+
+// groupImports maps entries to namespaces, sorted, dropping duplicate
+// secrets within a namespace.
+func (h *Handler) groupImports(entries []importer.Entry) []*importGroup {
+	groups := make(map[string]*importGroup)
+	for _, e := range entries {
+		host, path := resolver.Normalize(e.Protocol, e.Host, e.Path)
+		host = h.cfg.CanonicalHost(host)
+		ns := h.storeNamespace(host, path)
+
+		g, ok := groups[ns]
+		if !ok {
+			g = &importGroup{namespace: ns, host: host}
+			groups[ns] = g
+		}
+		if slices.ContainsFunc(g.choices, func(c importChoice) bool { return c.cred.Password == e.Password }) {
+			continue
+		}
+		g.choices = append(g.choices, importChoice{
+			origin: e.Source + " " + e.URL(),
+			host:   host,
+			path:   path,
+			cred: store.Credential{
+				Username:          e.Username,
+				Password:          e.Password,
+				PasswordExpiryUTC: e.PasswordExpiryUTC,
+				OAuthRefreshToken: e.OAuthRefreshToken,
+			},
+		})
+	}
+
+	sorted := make([]*importGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	slices.SortFunc(sorted, func(a, b *importGroup) int { return strings.Compare(a.namespace, b.namespace) })
+	return sorted
+}
+
+func (h *Handler) importGroup(g *importGroup, opts ImportOptions) (outcome, detail string) {
+	backend, err := h.storeForHost(g.host)
+	if err != nil {
+		return ImportFailed, err.Error()
+	}
+
+	choices := g.choices
+	existing, err := backend.Get(g.namespace)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return ImportFailed, fmt.Sprintf("reading %s: %v", backend.Name(), err)
+	default:
+		choices = []importChoice{{origin: "stored in " + backend.Name(), cred: *existing, stored: true}}
+		for _, c := range g.choices {
+			if c.cred.Password != existing.Password {
+				choices = append(choices, c)
+			}
+		}
+		if len(choices) == 1 {
+			return ImportUnchanged, "already stored"
+		}
+	}
+
+	chosen := 0
+	if len(choices) > 1 {
+		switch opts.Strategy {
+		case ImportSkip:
+			return ImportSkipped, fmt.Sprintf("%d different secrets", len(choices))
+		case ImportLast:
+			chosen = len(choices) - 1
+		case ImportInteractive:
+			if chosen, err = h.choose(g.namespace, choices); err != nil {
+				return ImportFailed, err.Error()
+			}
+			if chosen < 0 {
+				return ImportSkipped, "skipped on request"
+			}
+		}
+	}
+
+	c := choices[chosen]
+	if c.stored {
+		return ImportKept, "kept the stored secret"
+	}
+	if opts.DryRun {
+		return ImportWouldImport, "from " + c.origin
+	}
+
+	cred := c.cred
+	cred.CreatedAt = time.Now().Unix()
+	cred.Provider = provider.ForHost(c.host, h.cfg.ProviderForHost(c.host)).Name()
+	cred.Origin = strings.TrimSuffix(c.host+"/"+c.path, "/")
+	cred.HelperVersion = Version
+	if err := backend.Store(g.namespace, &cred); err != nil {
+		return ImportFailed, fmt.Sprintf("writing %s: %v", backend.Name(), err)
+	}
+	return ImportImported, "from " + c.origin
+}
+
+// ImportsHost reports whether to import credentials for host from a store
+// shared with other applications, like the macOS keychain: it must be a
+// configured host or alias, or one a provider recognizes.
+func (h *Handler) ImportsHost(host string) bool {
+	host = h.cfg.CanonicalHost(host)
+	if _, ok := h.cfg.Hosts[host]; ok {
+		return true
+	}
+	return provider.ForHost(host, h.cfg.ProviderForHost(host)).Name() != "generic"
+}
+
+// chooseOnTerminal asks which secret a namespace should get, returning its
+// index or -1 to skip the namespace.
+func (h *Handler) chooseOnTerminal(namespace string, choices []importChoice) (int, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open terminal to resolve conflict (use --strategy): %w", err)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "Different secrets for %s:\n", namespace)
+	for i, c := range choices {
+		fmt.Fprintf(tty, "  %d) %s  %s  (%s)\n", i+1, c.cred.Username, mask(c.cred.Password), c.origin)
+	}
+
+	reader := bufio.NewReader(tty)
+	for {
+		fmt.Fprintf(tty, "Choose 1-%d, or s to skip: ", len(choices))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("reading choice: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "s" {
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(choices) {
+			return n - 1, nil
+		}
+	}
+}
+
+// WriteImportReport prints results and a summary. It returns an error if
+// any namespace failed.
+func WriteImportReport(w io.Writer, results []ImportResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	counts := make(map[string]int)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Namespace, r.Outcome, r.Detail)
+		counts[r.Outcome]++
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d namespaces: %d imported, %d would import, %d unchanged, %d kept, %d skipped, %d failed\n",
+		len(results), counts[ImportImported], counts[ImportWouldImport], counts[ImportUnchanged], counts[ImportKept], counts[ImportSkipped], counts[ImportFailed])
+	if counts[ImportFailed] > 0 {
+		return fmt.Errorf("%d namespaces failed to import", counts[ImportFailed])
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/imcitius/git-credentials-org/internal/importer"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+func TestHandlerImport(t *testing.T) {
+	entries := []importer.Entry{
+		{Source: importer.SourceGitCredentials, Protocol: "https", Host: "gitlab.com", Path: "org1/repo.git", Username: "oauth2", Password: "glpat-one"},
+		{Source: importer.SourceOSXKeychain, Protocol: "https", Host: "gitlab.com", Path: "org1/other", Username: "oauth2", Password: "glpat-one"},
+		{Source: importer.SourceGitCredentials, Protocol: "https", Host: "gitlab.com", Path: "org2", Username: "oauth2", Password: "glpat-two"},
+		{Source: importer.SourceOSXKeychain, Protocol: "https", Host: "gitlab.com", Path: "org2/repo", Username: "oauth2", Password: "glpat-two-newer"},
+		{Source: importer.SourceLibsecret, Protocol: "https", Host: "github.com", Path: "acme/tools", Username: "x-access-token", Password: "ghp_acme"},
+	}
+	newHandler := func() (*mockStore, *Handler) {
+		mock := newMockStore()
+		mock.creds["github.com/acme"] = &store.Credential{Username: "x-access-token", Password: "ghp_acme"}
+		return mock, newTestHandler(testConfig(), mock, nil)
+	}
+
+	tests := []struct {
+		strategy string
+		choice   int
+		want     map[string]string
+		org2     string
+	}{
+		{ImportSkip, 0, map[string]string{"gitlab.com/org1": ImportImported, "gitlab.com/org2": ImportSkipped, "github.com/acme": ImportUnchanged}, ""},
+		{ImportFirst, 0, map[string]string{"gitlab.com/org1": ImportImported, "gitlab.com/org2": ImportImported, "github.com/acme": ImportUnchanged}, "glpat-two"},
+		{ImportLast, 0, map[string]string{"gitlab.com/org1": ImportImported, "gitlab.com/org2": ImportImported, "github.com/acme": ImportUnchanged}, "glpat-two-newer"},
+		{ImportInteractive, 1, map[string]string{"gitlab.com/org1": ImportImported, "gitlab.com/org2": ImportImported, "github.com/acme": ImportUnchanged}, "glpat-two-newer"},
+		{ImportInteractive, -1, map[string]string{"gitlab.com/org1": ImportImported, "gitlab.com/org2": ImportSkipped, "github.com/acme": ImportUnchanged}, ""},
+	}
+	for _, tt := range tests {
+		mock, h := newHandler()
+		var asked []string
+		h.choose = func(namespace string, choices []importChoice) (int, error) {
+			asked = append(asked, namespace)
+			return tt.choice, nil
+		}
+
+		results, err := h.Import(entries, ImportOptions{Strategy: tt.strategy})
+		if err != nil {
+			t.Fatalf("Import(%s) error = %v", tt.strategy, err)
+		}
+		if len(results) != len(tt.want) {
+			t.Fatalf("Import(%s) = %+v", tt.strategy, results)
+		}
+		for _, r := range results {
+			if r.Outcome != tt.want[r.Namespace] {
+				t.Errorf("Import(%s) %s = %s (%s), want %s", tt.strategy, r.Namespace, r.Outcome, r.Detail, tt.want[r.Namespace])
+			}
+		}
+		if tt.strategy == ImportInteractive && (len(asked) != 1 || asked[0] != "gitlab.com/org2") {
+			t.Errorf("Import(%s) asked about %v, want the conflicting namespace only", tt.strategy, asked)
+		}
+
+		got := mock.creds["gitlab.com/org2"]
+		switch {
+		case tt.org2 == "" && got != nil:
+			t.Errorf("Import(%s) stored %+v for a skipped conflict", tt.strategy, got)
+		case tt.org2 != "" && (got == nil || got.Password != tt.org2):
+			t.Errorf("Import(%s) stored %+v, want password %s", tt.strategy, got, tt.org2)
+		}
+		if one := mock.creds["gitlab.com/org1"]; one.CreatedAt == 0 || one.Origin != "gitlab.com/org1/repo" || one.HelperVersion != Version {
+			t.Errorf("Import(%s) stored %+v, want metadata", tt.strategy, one)
+		}
+	}
+}
+
+func TestHandlerImportStored(t *testing.T) {
+	mock := newMockStore()
+	mock.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-stored"}
+	h := newTestHandler(testConfig(), mock, nil)
+	entries := []importer.Entry{
+		{Source: importer.SourceGitCredentials, Protocol: "https", Host: "gitlab.com", Path: "org1", Username: "oauth2", Password: "glpat-old"},
+	}
+
+	results, err := h.Import(entries, ImportOptions{Strategy: ImportFirst})
+	if err != nil || len(results) != 1 || results[0].Outcome != ImportKept {
+		t.Fatalf("Import(first) = %+v, %v, want the stored secret kept", results, err)
+	}
+
+	results, err = h.Import(entries, ImportOptions{Strategy: ImportLast, DryRun: true})
+	if err != nil || len(results) != 1 || results[0].Outcome != ImportWouldImport {
+		t.Fatalf("Import(last, dry run) = %+v, %v", results, err)
+	}
+	if mock.creds["gitlab.com/org1"].Password != "glpat-stored" {
+		t.Error("Import(dry run) wrote to the backend")
+	}
+
+	var out bytes.Buffer
+	if err := WriteImportReport(&out, results); err != nil {
+		t.Errorf("WriteImportReport() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "1 namespaces: 0 imported, 1 would import, 0 unchanged, 0 kept, 0 skipped, 0 failed") {
+		t.Errorf("WriteImportReport() = \n%s", out.String())
+	}
+
+	if _, err := h.Import(entries, ImportOptions{Strategy: "newest"}); err == nil {
+		t.Error("Import() with an unknown strategy should fail")
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// ListEntry describes a stored credential without its secrets.
+type ListEntry struct {
+	Namespace string     `json:"namespace"`
+	Username  string     `json:"username"`
+	Backend   string     `json:"backend"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	Provider      string     `json:"provider,omitempty"`
+	Origin        string     `json:"origin,omitempty"`
+	HelperVersion string     `json:"helper_version,omitempty"`
+
+	// Error is why the credential couldn't be read, e.g. a KeePass entry
+	// without a password; the other fields are then empty.
+	Error string `json:"error,omitempty"`
+}
+
+// newListEntry describes cred, stored at namespace in backend.
+func newListEntry(namespace, backend string, cred *store.Credential) ListEntry {
+	return ListEntry{
+		Namespace:     namespace,
+		Username:      cred.Username,
+		Backend:       backend,
+		ExpiresAt:     unixTime(cred.PasswordExpiryUTC),
+		CreatedAt:     unixTime(cred.CreatedAt),
+		LastUsedAt:    unixTime(cred.LastUsedAt),
+		Provider:      cred.Provider,
+		Origin:        cred.Origin,
+		HelperVersion: cred.HelperVersion,
+	}
+}
+
+// unixTime returns the UTC time of a Unix timestamp, nil if it is zero.
+func unixTime(t int64) *time.Time {
+	if t == 0 {
+		return nil
+	}
+	u := time.Unix(t, 0).UTC()
+	return &u
+}
+
+// List returns the credentials in the configured backends, or in backend
+// only if it is set. Backends that can't enumerate their credentials, or
+// fail to (e.g. a locked vault), are reported in warnings and skipped
+// unless backend names them; credentials that can't be read are returned
+// with Error set.
+func (h *Handler) List(backend string) (entries []ListEntry, warnings []string, err error) {
+	names := h.cfg.BackendNames()
+	if backend != "" {
+		names = []string{backend}
+	}
+
+	for _, name := range names {
+		namespaces, s, err := h.listBackend(name)
+		if err != nil {
+			if backend != "" {
+				return nil, nil, err
+			}
+			// One backend failing shouldn't hide the others.
+			h.log("backend %s: %v", name, err)
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		h.log("backend %s: %d credentials", name, len(namespaces))
+
+		for _, ns := range namespaces {
+			cred, err := s.Get(ns)
+			if err != nil {
+				// One broken entry shouldn't hide the others.
+				h.log("backend %s: reading %s: %v", name, ns, err)
+				entries = append(entries, ListEntry{Namespace: ns, Backend: name, Error: err.Error()})
+				continue
+			}
+			entries = append(entries, newListEntry(ns, name, cred))
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b ListEntry) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+	return entries, warnings, nil
+}
+
+// listBackend returns the namespaces stored in the backend name.
+func (h *Handler) listBackend(name string) ([]string, store.CredentialStore, error) {
+	s, err := h.newStore(name, h.cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("backend %q: %w", name, err)
+	}
+	lister, ok := s.(store.Lister)
+	if !ok {
+		return nil, nil, fmt.Errorf("backend %q can't list its credentials", name)
+	}
+	namespaces, err := lister.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("backend %q: listing credentials: %w", name, err)
+	}
+	return namespaces, s, nil
+}
+
+// WriteList prints entries as a table, or as a JSON array with asJSON.
+func WriteList(w io.Writer, entries []ListEntry, asJSON bool) error {
+	if asJSON {
+		if entries == nil {
+			entries = []ListEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tUSERNAME\tBACKEND\tEXPIRES\tLAST USED")
+	for _, e := range entries {
+		if e.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Namespace, "(unreadable)", e.Backend, "-", "-")
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Namespace, e.Username, e.Backend, formatTime(e.ExpiresAt), formatTime(e.LastUsedAt))
+	}
+	return tw.Flush()
+}
+
+// formatTime formats an optional time for tables, "-" if unset.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
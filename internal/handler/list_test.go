@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// listingStore is a mockStore that can enumerate its credentials.
+type listingStore struct {
+	*mockStore
+}
+
+func (l listingStore) List() ([]string, error) {
+	var namespaces []string
+	for ns := range l.creds {
+		namespaces = append(namespaces, ns)
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+func TestHandlerList(t *testing.T) {
+	file := listingStore{newMockStore()}
+	file.creds["gitlab.com/org2"] = &store.Credential{Username: "oauth2", Password: "glpat-secret", PasswordExpiryUTC: 1767225600, LastUsedAt: 1760000000, Provider: "gitlab"}
+	op := listingStore{newMockStore()}
+	op.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-other"}
+
+	cfg := testConfig()
+	cfg.Defaults.Backend = "file"
+	cfg.Hosts["gitlab.com"] = config.HostConfig{Backend: "onepassword"}
+	cfg.Hosts["github.com"] = config.HostConfig{Backend: "vault"}
+	cfg.Hosts["bitbucket.org"] = config.HostConfig{Backend: "keepass"}
+	h := newTestHandler(cfg, nil, nil)
+	h.newStore = func(name string, _ *config.Config) (store.CredentialStore, error) {
+		switch name {
+		case "file":
+			return file, nil
+		case "onepassword":
+			return op, nil
+		case "keepass":
+			return lockedStore{listingStore{newMockStore()}}, nil
+		}
+		return newMockStore(), nil
+	}
+
+	entries, warnings, err := h.List("")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{`backend "keepass": listing credentials: database is locked`, `backend "vault" can't list its credentials`}
+	if !slices.Equal(warnings, want) {
+		t.Errorf("List() warnings = %q, want %q", warnings, want)
+	}
+	if len(entries) != 2 || entries[0].Namespace != "gitlab.com/org1" || entries[0].Backend != "onepassword" ||
+		entries[1].Backend != "file" || entries[1].ExpiresAt == nil || entries[1].ExpiresAt.Unix() != 1767225600 {
+		t.Fatalf("List() = %+v", entries)
+	}
+
+	var table bytes.Buffer
+	if err := WriteList(&table, entries, false); err != nil {
+		t.Fatalf("WriteList() error = %v", err)
+	}
+	if !strings.Contains(table.String(), "gitlab.com/org2  oauth2    file         2026-01-01 00:00  2025-10-09 08:53") {
+		t.Errorf("WriteList() table = \n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteList(&out, entries, true); err != nil {
+		t.Fatalf("WriteList() error = %v", err)
+	}
+	if strings.Contains(out.String(), "glpat-") {
+		t.Errorf("WriteList() leaked a secret: %s", out.String())
+	}
+	var decoded []ListEntry
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].Provider != "gitlab" {
+		t.Errorf("WriteList() JSON = %s, %v", out.String(), err)
+	}
+
+	entries, _, err = h.List("onepassword")
+	if err != nil || len(entries) != 1 {
+		t.Errorf("List(onepassword) = %+v, %v", entries, err)
+	}
+	if _, _, err := h.List("keepass"); err == nil {
+		t.Error("List(keepass) should fail when the only backend asked for does")
+	}
+}
+
+// lockedStore fails to list its credentials, like a locked database.
+type lockedStore struct {
+	listingStore
+}
+
+func (lockedStore) List() ([]string, error) {
+	return nil, errors.New("database is locked")
+}
+
+// unreadableStore lists a namespace it can't return, like a KeePass entry
+// without a password.
+type unreadableStore struct {
+	listingStore
+}
+
+func (u unreadableStore) List() ([]string, error) {
+	namespaces, _ := u.listingStore.List()
+	return append(namespaces, "gitlab.com/broken"), nil
+}
+
+func TestHandlerListUnreadable(t *testing.T) {
+	keepass := unreadableStore{listingStore{newMockStore()}}
+	keepass.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-abc"}
+	h := newTestHandler(testConfig(), nil, nil)
+	h.newStore = func(string, *config.Config) (store.CredentialStore, error) {
+		return keepass, nil
+	}
+
+	entries, _, err := h.List("")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Namespace != "gitlab.com/broken" || entries[0].Error == "" || entries[1].Username != "oauth2" {
+		t.Fatalf("List() = %+v, want the readable entry and the broken one marked", entries)
+	}
+
+	var table bytes.Buffer
+	if err := WriteList(&table, entries, false); err != nil {
+		t.Fatalf("WriteList() error = %v", err)
+	}
+	if !strings.Contains(table.String(), "gitlab.com/broken  (unreadable)") {
+		t.Errorf("WriteList() table = \n%s", table.String())
+	}
+}
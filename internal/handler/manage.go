@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/provider"
+	"github.com/imcitius/git-credentials-org/internal/resolver"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// parseNamespace returns a namespace given on the command line (e.g.
+// "gitlab.com/org1" or "https://gitlab.com/org1/") in canonical form,
+// together with the host whose backend holds it. An argument is a host
+// namespace unless it is the namespace of a [[namespaces]] rule and doesn't
+// start with a configured host or alias. Rule namespaces are stored under
+// the host of the repositories they match, which hostFlag names if the
+// rules don't tell.
+func (h *Handler) parseNamespace(arg, hostFlag string) (namespace, host string, err error) {
+	arg = strings.TrimSpace(arg)
+	if _, rest, ok := strings.Cut(arg, "://"); ok {
+		arg = rest
+	}
+	arg = strings.Trim(arg, "/")
+	if arg == "" {
+		return "", "", errors.New("namespace is required")
+	}
+	if hostFlag != "" {
+		hostFlag, _ = resolver.Normalize("https", hostFlag, "")
+		hostFlag = h.cfg.CanonicalHost(hostFlag)
+	}
+
+	first, path, _ := strings.Cut(arg, "/")
+	if !h.configuredHost(first) && h.isRuleNamespace(arg) {
+		if hostFlag != "" {
+			return arg, hostFlag, nil
+		}
+		host, err := h.ruleHost(arg)
+		return arg, host, err
+	}
+
+	host, path = resolver.Normalize("https", first, path)
+	host = h.cfg.CanonicalHost(host)
+	if hostFlag != "" && hostFlag != host {
+		return "", "", fmt.Errorf("--host %s doesn't match the host of %s", hostFlag, arg)
+	}
+	if path == "" {
+		return host, host, nil
+	}
+	return host + "/" + path, host, nil
+}
+
+// configuredHost reports whether name is a host of the config or one of
+// their aliases.
+func (h *Handler) configuredHost(name string) bool {
+	host, _ := resolver.Normalize("https", name, "")
+	_, ok := h.cfg.Hosts[h.cfg.CanonicalHost(host)]
+	return ok
+}
+
+func (h *Handler) isRuleNamespace(namespace string) bool {
+	return slices.ContainsFunc(h.cfg.Namespaces, func(r config.NamespaceRule) bool {
+		return r.Namespace == namespace
+	})
+}
+
+// ruleHost returns a host whose backend holds a rule namespace: the host
+// its rules match, or any host if all the hosts they could match share a
+// backend. Rules matching by regex or with a pattern for the host could
+// match any host, including ones not configured.
+func (h *Handler) ruleHost(namespace string) (string, error) {
+	var hosts []string
+	for _, r := range h.cfg.Namespaces {
+		if r.Namespace != namespace {
+			continue
+		}
+		first, _, _ := strings.Cut(r.Match, "/")
+		if r.Regex != "" || first == "" || strings.ContainsAny(first, `*?[\`) {
+			hosts = append(hosts, "")
+			for host := range h.cfg.Hosts {
+				hosts = append(hosts, host)
+			}
+			continue
+		}
+		host, _ := resolver.Normalize("https", first, "")
+		hosts = append(hosts, h.cfg.CanonicalHost(host))
+	}
+	slices.Sort(hosts)
+	hosts = slices.Compact(hosts)
+
+	chain := h.cfg.ChainForHost(hosts[0])
+	for _, host := range hosts[1:] {
+		other := h.cfg.ChainForHost(host)
+		if !slices.Equal(other.Backends, chain.Backends) || other.WritePolicy != chain.WritePolicy || other.Primary != chain.Primary {
+			return "", fmt.Errorf("%s may be stored in the backends of several hosts; choose one with --host", namespace)
+		}
+	}
+	return hosts[0], nil
+}
+
+// Set saves a credential for a namespace given on the command line. The
+// token is read from the first line of token, or prompted for if token is
+// nil. username defaults to the provider's. host names the host whose
+// backend stores a rule namespace, if the rules don't tell.
+func (h *Handler) Set(arg, host string, token io.Reader, username string) error {
+	namespace, host, err := h.parseNamespace(arg, host)
+	if err != nil {
+		return err
+	}
+	prov := provider.ForHost(host, h.cfg.ProviderForHost(host))
+
+	var cred *store.Credential
+	if token == nil {
+		if cred, err = h.prompt(prov, namespace); err != nil {
+			return fmt.Errorf("prompting for credentials: %w", err)
+		}
+	} else {
+		line, err := bufio.NewReader(token).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading token: %w", err)
+		}
+		cred = &store.Credential{Username: prov.DefaultUsername(), Password: strings.TrimSpace(line)}
+	}
+	if username != "" {
+		cred.Username = username
+	}
+	if cred.Password == "" {
+		return errors.New("empty token")
+	}
+
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return err
+	}
+
+	existing, err := backend.Get(namespace)
+	switch {
+	case err == nil && existing.Password == cred.Password:
+		keepMetadata(cred, existing)
+	case err == nil || errors.Is(err, store.ErrNotFound):
+		cred.CreatedAt = time.Now().Unix()
+		cred.Provider = prov.Name()
+		cred.Origin = namespace
+		cred.HelperVersion = Version
+	default:
+		return fmt.Errorf("backend %s get: %w", backend.Name(), err)
+	}
+
+	h.log("set: storing namespace=%s in %s", namespace, backend.Name())
+	if err := backend.Store(namespace, cred); err != nil {
+		return fmt.Errorf("backend %s store: %w", backend.Name(), err)
+	}
+	return nil
+}
+
+// Show returns the credential stored for a namespace given on the command
+// line, with its description. host is as for Set.
+func (h *Handler) Show(arg, host string) (ListEntry, *store.Credential, error) {
+	namespace, host, err := h.parseNamespace(arg, host)
+	if err != nil {
+		return ListEntry{}, nil, err
+	}
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return ListEntry{}, nil, err
+	}
+
+	cred, err := backend.Get(namespace)
+	if errors.Is(err, store.ErrNotFound) {
+		return ListEntry{}, nil, fmt.Errorf("no credential stored for %s", namespace)
+	}
+	if err != nil {
+		return ListEntry{}, nil, fmt.Errorf("backend %s get: %w", backend.Name(), err)
+	}
+
+	backendName := strings.Join(h.cfg.BackendsForHost(host), ",")
+	return newListEntry(namespace, backendName, cred), cred, nil
+}
+
+// Delete removes the credential stored for a namespace given on the
+// command line. host is as for Set.
+func (h *Handler) Delete(arg, host string) error {
+	namespace, host, err := h.parseNamespace(arg, host)
+	if err != nil {
+		return err
+	}
+	backend, err := h.storeForHost(host)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.Get(namespace); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no credential stored for %s", namespace)
+		}
+		return fmt.Errorf("backend %s get: %w", backend.Name(), err)
+	}
+
+	h.log("delete: removing namespace=%s from %s", namespace, backend.Name())
+	if err := backend.Erase(namespace); err != nil {
+		return fmt.Errorf("backend %s erase: %w", backend.Name(), err)
+	}
+	return nil
+}
+
+// WriteShow prints a credential described by entry. Secrets are masked
+// unless reveal is set.
+func WriteShow(w io.Writer, entry ListEntry, cred *store.Credential, reveal bool) error {
+	secret := func(s string) string {
+		if reveal {
+			return s
+		}
+		return mask(s)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fields := []struct{ name, value string }{
+		{"namespace", entry.Namespace},
+		{"backend", entry.Backend},
+		{"username", entry.Username},
+		{"secret", secret(cred.Password)},
+		{"authtype", cred.AuthType},
+		{"refresh token", secret(cred.OAuthRefreshToken)},
+		{"expires", formatOptionalTime(entry.ExpiresAt)},
+		{"created", formatOptionalTime(entry.CreatedAt)},
+		{"last used", formatOptionalTime(entry.LastUsedAt)},
+		{"provider", entry.Provider},
+		{"origin", entry.Origin},
+		{"helper version", entry.HelperVersion},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", f.name, f.value)
+		}
+	}
+	return tw.Flush()
+}
+
+// mask hides a secret, keeping a few leading characters of long ones so
+// token types (glpat-, ghp_) stay recognizable.
+func mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) < 16 {
+		return "********"
+	}
+	return s[:4] + "********"
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(t)
+}
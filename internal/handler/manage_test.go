@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+func TestParseNamespace(t *testing.T) {
+	cfg := testConfig()
+	cfg.Hosts["gitlab.company.com"] = config.HostConfig{Aliases: []string{"git.company.com"}}
+	cfg.Hosts["gitlab"] = config.HostConfig{Backend: "pass"}
+	cfg.Hosts["github.com"] = config.HostConfig{Backend: "onepassword"}
+	cfg.Namespaces = []config.NamespaceRule{
+		{Match: "github.com/acme-*", Namespace: "acme"},
+		{Match: "gitlab.company.com/platform/*", Namespace: "platform"},
+		{Match: "git.company.com/infra/*", Namespace: "platform"},
+		{Regex: `/shared-`, Namespace: "shared"},
+		{Match: "*/tools", Namespace: "tools"},
+		{Match: "gitlab/org1/*", Namespace: "gitlab/org1"},
+	}
+	h := newTestHandler(cfg, nil, nil)
+
+	tests := []struct {
+		arg, hostFlag, namespace, host string
+	}{
+		{"gitlab.com/org1", "", "gitlab.com/org1", "gitlab.com"},
+		{"https://GitLab.com:443/org1/", "", "gitlab.com/org1", "gitlab.com"},
+		{"git.company.com/platform/infra", "", "gitlab.company.com/platform/infra", "gitlab.company.com"},
+		{"github.com", "", "github.com", "github.com"},
+		{"gitlab.com/org1", "GitLab.com", "gitlab.com/org1", "gitlab.com"},
+		// Short intranet hosts.
+		{"gitlab/org1", "", "gitlab/org1", "gitlab"},
+		{"intranet/team", "", "intranet/team", "intranet"},
+		// Rule namespaces are stored with the hosts they match.
+		{"acme", "", "acme", "github.com"},
+		{"platform", "", "platform", "gitlab.company.com"},
+		{"tools", "gitlab", "tools", "gitlab"},
+		{"shared", "git.company.com", "shared", "gitlab.company.com"},
+	}
+	for _, tt := range tests {
+		namespace, host, err := h.parseNamespace(tt.arg, tt.hostFlag)
+		if err != nil || namespace != tt.namespace || host != tt.host {
+			t.Errorf("parseNamespace(%q, %q) = %q, %q, %v, want %q, %q", tt.arg, tt.hostFlag, namespace, host, err, tt.namespace, tt.host)
+		}
+	}
+
+	for _, arg := range []string{" / ", "shared", "tools"} {
+		if _, _, err := h.parseNamespace(arg, ""); err == nil {
+			t.Errorf("parseNamespace(%q) should fail", arg)
+		}
+	}
+	if _, _, err := h.parseNamespace("gitlab.com/org1", "github.com"); err == nil {
+		t.Error("parseNamespace() should reject a --host other than the namespace's")
+	}
+}
+
+func TestHandlerSetShowDelete(t *testing.T) {
+	mock := newMockStore()
+	cfg := testConfig()
+	cfg.Hosts["gitlab.com"] = config.HostConfig{Provider: "gitlab"}
+	h := newTestHandler(cfg, mock, &store.Credential{Username: "oauth2", Password: "glpat-prompted"})
+
+	if err := h.Set("gitlab.com/org1", "", strings.NewReader("glpat-abcdefghijklmnop\n"), ""); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got := mock.creds["gitlab.com/org1"]
+	if got == nil || got.Username != "oauth2" || got.Password != "glpat-abcdefghijklmnop" ||
+		got.Provider != "gitlab" || got.CreatedAt == 0 {
+		t.Fatalf("Set() stored %+v", got)
+	}
+
+	if err := h.Set("gitlab.com/org2", "", nil, "deploy"); err != nil {
+		t.Fatalf("Set() with prompt error = %v", err)
+	}
+	if got := mock.creds["gitlab.com/org2"]; got == nil || got.Username != "deploy" || got.Password != "glpat-prompted" {
+		t.Errorf("Set() with prompt stored %+v", got)
+	}
+
+	entry, cred, err := h.Show("https://gitlab.com/org1", "")
+	if err != nil {
+		t.Fatalf("Show() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := WriteShow(&out, entry, cred, false); err != nil {
+		t.Fatalf("WriteShow() error = %v", err)
+	}
+	if strings.Contains(out.String(), "abcdefghijklmnop") || !strings.Contains(out.String(), "secret:         glpa********") {
+		t.Errorf("WriteShow() = \n%s, want masked secret", out.String())
+	}
+	out.Reset()
+	WriteShow(&out, entry, cred, true)
+	if !strings.Contains(out.String(), "glpat-abcdefghijklmnop") {
+		t.Errorf("WriteShow(reveal) = \n%s", out.String())
+	}
+
+	if err := h.Delete("gitlab.com/org1", ""); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := mock.creds["gitlab.com/org1"]; ok {
+		t.Error("Delete() left the credential")
+	}
+	if err := h.Delete("gitlab.com/org1", ""); err == nil {
+		t.Error("Delete() of a missing credential should fail")
+	}
+	if _, _, err := h.Show("gitlab.com/org1", ""); err == nil {
+		t.Error("Show() of a missing credential should fail")
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/imcitius/git-credentials-org/internal/resolver"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// MigrateOptions selects what Migrate copies.
+type MigrateOptions struct {
+	From, To string
+
+	// Namespace is a glob on namespaces (e.g. "gitlab.com/*"), which also
+	// selects the namespaces below those it matches; empty migrates
+	// everything. A source that can't list its credentials
+	// needs an exact namespace.
+	Namespace string
+
+	// DeleteSource erases each credential from From once its copy is
+	// verified.
+	DeleteSource bool
+	DryRun       bool
+}
+
+// Outcomes of migrating a namespace.
+const (
+	MigrateCopied    = "copied"
+	MigrateMoved     = "moved"
+	MigrateSkipped   = "skipped"
+	MigrateFailed    = "failed"
+	MigrateWouldCopy = "would copy"
+)
+
+// MigrateResult is the outcome of migrating one namespace; Detail explains
+// skips and failures.
+type MigrateResult struct {
+	Namespace string
+	Outcome   string
+	Detail    string
+}
+
+// Migrate copies credentials, with their metadata, from one backend to
+// another. Each copy is read back from the destination before the source
+// is (optionally) erased. A destination already holding a different
+// secret for a namespace is left alone.
+func (h *Handler) Migrate(opts MigrateOptions) ([]MigrateResult, error) {
+	if opts.From == opts.To {
+		return nil, errors.New("source and destination backends are the same")
+	}
+	if opts.Namespace != "" {
+		if _, err := path.Match(opts.Namespace, ""); err != nil {
+			return nil, fmt.Errorf("bad namespace pattern %q: %w", opts.Namespace, err)
+		}
+	}
+
+	from, err := h.newStore(opts.From, h.cfg)
+	if err != nil {
+		return nil, err
+	}
+	to, err := h.newStore(opts.To, h.cfg)
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	if lister, ok := from.(store.Lister); ok {
+		if namespaces, err = lister.List(); err != nil {
+			return nil, err
+		}
+	} else if opts.Namespace != "" && !strings.ContainsAny(opts.Namespace, `*?[\`) {
+		namespaces = []string{opts.Namespace}
+	} else {
+		return nil, fmt.Errorf("backend %q can't list its credentials; name the namespace to migrate with --namespace", opts.From)
+	}
+
+	var results []MigrateResult
+	for _, ns := range namespaces {
+		if opts.Namespace != "" {
+			if !resolver.MatchGlob(opts.Namespace, ns) {
+				continue
+			}
+		}
+		outcome, detail := h.migrate(from, to, ns, opts)
+		h.log("migrate: %s %s %s", ns, outcome, detail)
+		results = append(results, MigrateResult{Namespace: ns, Outcome: outcome, Detail: detail})
+	}
+	return results, nil
+}
+
+func (h *Handler) migrate(from, to store.CredentialStore, namespace string, opts MigrateOptions) (outcome, detail string) {
+	cred, err := from.Get(namespace)
+	if errors.Is(err, store.ErrNotFound) {
+		return MigrateSkipped, fmt.Sprintf("not in %s", opts.From)
+	}
+	if err != nil {
+		return MigrateFailed, fmt.Sprintf("reading %s: %v", opts.From, err)
+	}
+
+	existing, err := to.Get(namespace)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		existing = nil
+	case err != nil:
+		return MigrateFailed, fmt.Sprintf("reading %s: %v", opts.To, err)
+	case existing.Password != cred.Password:
+		return MigrateSkipped, fmt.Sprintf("%s has a different credential", opts.To)
+	}
+
+	if opts.DryRun {
+		if existing != nil {
+			return MigrateSkipped, fmt.Sprintf("already in %s", opts.To)
+		}
+		if opts.DeleteSource {
+			return MigrateWouldCopy, fmt.Sprintf("then erase from %s", opts.From)
+		}
+		return MigrateWouldCopy, ""
+	}
+
+	if existing == nil {
+		if err := to.Store(namespace, cred); err != nil {
+			return MigrateFailed, fmt.Sprintf("writing %s: %v", opts.To, err)
+		}
+		copied, err := to.Get(namespace)
+		if err != nil {
+			return MigrateFailed, fmt.Sprintf("verifying %s: %v", opts.To, err)
+		}
+		if !sameSecret(copied, cred) {
+			return MigrateFailed, fmt.Sprintf("verifying %s: read back a different credential", opts.To)
+		}
+	}
+
+	if !opts.DeleteSource {
+		if existing != nil {
+			return MigrateSkipped, fmt.Sprintf("already in %s", opts.To)
+		}
+		return MigrateCopied, ""
+	}
+	if err := from.Erase(namespace); err != nil {
+		return MigrateFailed, fmt.Sprintf("copied, but erasing from %s: %v", opts.From, err)
+	}
+	return MigrateMoved, ""
+}
+
+// sameSecret reports whether two credentials authenticate the same way,
+// regardless of metadata.
+func sameSecret(a, b *store.Credential) bool {
+	return a.Username == b.Username &&
+		a.Password == b.Password &&
+		a.PasswordExpiryUTC == b.PasswordExpiryUTC &&
+		a.OAuthRefreshToken == b.OAuthRefreshToken &&
+		a.AuthType == b.AuthType
+}
+
+// WriteMigrateReport prints results and a summary. It returns an error if
+// any namespace failed.
+func WriteMigrateReport(w io.Writer, results []MigrateResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	counts := make(map[string]int)
+	for _, r := range results {
+		if r.Detail == "" {
+			fmt.Fprintf(tw, "%s\t%s\n", r.Namespace, r.Outcome)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Namespace, r.Outcome, r.Detail)
+		}
+		counts[r.Outcome]++
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d namespaces: %d copied, %d moved, %d would copy, %d skipped, %d failed\n",
+		len(results), counts[MigrateCopied], counts[MigrateMoved], counts[MigrateWouldCopy], counts[MigrateSkipped], counts[MigrateFailed])
+	if counts[MigrateFailed] > 0 {
+		return fmt.Errorf("%d namespaces failed to migrate", counts[MigrateFailed])
+	}
+	return nil
+}
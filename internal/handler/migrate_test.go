@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+func TestHandlerMigrate(t *testing.T) {
+	newStores := func() (listingStore, *mockStore, *Handler) {
+		file := listingStore{newMockStore()}
+		file.creds["gitlab.com/org1"] = &store.Credential{Username: "oauth2", Password: "glpat-one", CreatedAt: 1700000000, Provider: "gitlab"}
+		file.creds["gitlab.com/org2"] = &store.Credential{Username: "oauth2", Password: "glpat-two"}
+		file.creds["gitlab.com/company/team-a"] = &store.Credential{Username: "oauth2", Password: "glpat-team"}
+		file.creds["github.com/acme"] = &store.Credential{Username: "x-access-token", Password: "ghp_acme"}
+		op := newMockStore()
+		op.creds["gitlab.com/org2"] = &store.Credential{Username: "oauth2", Password: "glpat-newer"}
+
+		h := newTestHandler(testConfig(), nil, nil)
+		h.newStore = func(name string, _ *config.Config) (store.CredentialStore, error) {
+			if name == "file" {
+				return file, nil
+			}
+			return op, nil
+		}
+		return file, op, h
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		_, op, h := newStores()
+		results, err := h.Migrate(MigrateOptions{From: "file", To: "onepassword", DryRun: true})
+		if err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+		if len(results) != 4 || len(op.creds) != 1 {
+			t.Errorf("Migrate(dry run) = %+v, destination %v", results, op.creds)
+		}
+	})
+
+	t.Run("move", func(t *testing.T) {
+		file, op, h := newStores()
+		results, err := h.Migrate(MigrateOptions{From: "file", To: "onepassword", Namespace: "gitlab.com/*", DeleteSource: true})
+		if err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		// "*" doesn't match "/", but namespaces below a match are included.
+		want := map[string]string{"gitlab.com/org1": MigrateMoved, "gitlab.com/org2": MigrateSkipped, "gitlab.com/company/team-a": MigrateMoved}
+		if len(results) != len(want) {
+			t.Fatalf("Migrate() = %+v, want gitlab.com namespaces only", results)
+		}
+		for _, r := range results {
+			if r.Outcome != want[r.Namespace] {
+				t.Errorf("Migrate() %s = %s (%s), want %s", r.Namespace, r.Outcome, r.Detail, want[r.Namespace])
+			}
+		}
+
+		if got := op.creds["gitlab.com/org1"]; got == nil || got.CreatedAt != 1700000000 || got.Provider != "gitlab" {
+			t.Errorf("Migrate() copied %+v, want metadata kept", got)
+		}
+		if _, ok := file.creds["gitlab.com/org1"]; ok {
+			t.Error("Migrate() didn't delete the moved source credential")
+		}
+		if op.creds["gitlab.com/org2"].Password != "glpat-newer" || file.creds["gitlab.com/org2"] == nil {
+			t.Error("Migrate() touched a conflicting namespace")
+		}
+
+		var out bytes.Buffer
+		if err := WriteMigrateReport(&out, results); err != nil {
+			t.Errorf("WriteMigrateReport() error = %v", err)
+		}
+		if !strings.Contains(out.String(), "3 namespaces: 0 copied, 2 moved, 0 would copy, 1 skipped, 0 failed") {
+			t.Errorf("WriteMigrateReport() = \n%s", out.String())
+		}
+	})
+
+	t.Run("unlistable source", func(t *testing.T) {
+		_, op, h := newStores()
+		if _, err := h.Migrate(MigrateOptions{From: "onepassword", To: "file", Namespace: "gitlab.com/*"}); err == nil {
+			t.Error("Migrate() of a pattern from a backend that can't list should fail")
+		}
+
+		op.creds["gitlab.com/org3"] = &store.Credential{Username: "oauth2", Password: "glpat-three"}
+		for ns, want := range map[string]string{"gitlab.com/org3": MigrateCopied, "gitlab.com/org4": MigrateSkipped} {
+			results, err := h.Migrate(MigrateOptions{From: "onepassword", To: "file", Namespace: ns})
+			if err != nil {
+				t.Fatalf("Migrate(%s) error = %v", ns, err)
+			}
+			if len(results) != 1 || results[0].Outcome != want {
+				t.Errorf("Migrate(%s) = %+v, want %s", ns, results, want)
+			}
+		}
+	})
+}
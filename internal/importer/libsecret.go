@@ -0,0 +1,43 @@
+package importer
+
+import (
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// libsecretSchema is the schema git-credential-libsecret saves items with.
+const libsecretSchema = "org.git.Password"
+
+// ReadLibsecret reads the items git-credential-libsecret saved in the
+// default Secret Service collection. search is store.SearchSecretService,
+// replaced in tests.
+func ReadLibsecret(search func(collection string, attrs map[string]string) ([]store.SecretItem, error)) ([]Entry, error) {
+	items, err := search("default", map[string]string{"xdg:schema": libsecretSchema})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, item := range items {
+		attrs := item.Attributes
+		if attrs["server"] == "" || len(item.Secret) == 0 {
+			continue
+		}
+
+		host := attrs["server"]
+		if port := attrs["port"]; port != "" && port != "0" {
+			host += ":" + port
+		}
+		e := Entry{
+			Source:   SourceLibsecret,
+			Protocol: attrs["protocol"],
+			Host:     host,
+			Path:     attrs["object"],
+			Username: attrs["user"],
+		}
+		parseSecretLines(&e, string(item.Secret))
+		if e.Password != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
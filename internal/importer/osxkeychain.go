@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/imcitius/git-credentials-org/internal/store"
+)
+
+// osxkeychainProtocols maps the keychain's protocol codes to git's.
+var osxkeychainProtocols = map[string]string{
+	"htps": "https",
+	"http": "http",
+}
+
+// ReadOSXKeychain reads the internet passwords git-credential-osxkeychain
+// saved in the login keychain. The keychain also holds website passwords,
+// so only items for hosts that want reports true are read; macOS may ask
+// to allow access to each of them. run executes the security command and
+// is replaced in tests.
+func ReadOSXKeychain(run func(args ...string) ([]byte, error), want func(host string) bool) ([]Entry, error) {
+	if run == nil {
+		run = runSecurity
+	}
+
+	dump, err := run("dump-keychain")
+	if err != nil {
+		return nil, fmt.Errorf("listing keychain items: %w", err)
+	}
+
+	var entries []Entry
+	for _, item := range store.ParseKeychainDump(dump, "inet") {
+		protocol := osxkeychainProtocols[item["ptcl"]]
+		host := item["srvr"]
+		if protocol == "" || host == "" || item["acct"] == "" {
+			continue
+		}
+		if port, err := strconv.ParseUint(item["port"], 0, 16); err == nil && port != 0 {
+			host += ":" + strconv.FormatUint(port, 10)
+		}
+		if !want(host) {
+			continue
+		}
+
+		args := []string{"find-internet-password", "-s", item["srvr"], "-a", item["acct"], "-r", item["ptcl"]}
+		if item["path"] != "" {
+			args = append(args, "-p", item["path"])
+		}
+		secret, err := run(append(args, "-w")...)
+		if err != nil {
+			return nil, fmt.Errorf("reading keychain password for %s: %w", host, err)
+		}
+
+		e := Entry{
+			Source:   SourceOSXKeychain,
+			Protocol: protocol,
+			Host:     host,
+			Path:     strings.TrimPrefix(item["path"], "/"),
+			Username: item["acct"],
+		}
+		parseSecretLines(&e, strings.TrimSuffix(string(secret), "\n"))
+		if e.Password != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func runSecurity(args ...string) ([]byte, error) {
+	cmd := exec.Command("security", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
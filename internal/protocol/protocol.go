@@ -2,17 +2,50 @@ package protocol
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
+// ErrURLConflict is returned when a url= attribute disagrees with an
+// explicit attribute that was given before it.
+var ErrURLConflict = errors.New("url attribute conflicts with explicit attribute")
+
+// CapabilityAuthType signals support for pre-encoded credentials via the
+// authtype and credential attributes instead of username and password.
+const CapabilityAuthType = "authtype"
+
 type Credential struct {
 	Protocol string
 	Host     string
 	Path     string
 	Username string
 	Password string
+
+	// PasswordExpiryUTC is the Unix time after which Password is no longer
+	// valid (password_expiry_utc attribute). Zero means no known expiry.
+	PasswordExpiryUTC int64
+
+	// OAuthRefreshToken is forwarded by git so OAuth helpers can refresh
+	// Password without prompting (oauth_refresh_token attribute).
+	OAuthRefreshToken string
+
+	// Capabilities lists the capability[] values announced by git on input,
+	// or by us on output.
+	Capabilities []string
+
+	// AuthType and Credential carry a pre-encoded credential such as a
+	// bearer token (authtype and credential attributes). Only valid when
+	// both sides announce CapabilityAuthType.
+	AuthType   string
+	Credential string
+
+	// WWWAuth holds the WWW-Authenticate challenges git received from the
+	// server, in the order they were sent (wwwauth[] attribute).
+	WWWAuth []string
 }
 
 func Parse(r io.Reader) (*Credential, error) {
@@ -31,6 +64,10 @@ func Parse(r io.Reader) (*Credential, error) {
 		}
 
 		switch key {
+		case "url":
+			if err := cred.applyURL(value); err != nil {
+				return nil, err
+			}
 		case "protocol":
 			cred.Protocol = value
 		case "host":
@@ -41,6 +78,33 @@ func Parse(r io.Reader) (*Credential, error) {
 			cred.Username = value
 		case "password":
 			cred.Password = value
+		case "password_expiry_utc":
+			// Git ignores malformed timestamps rather than failing the request.
+			expiry, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			cred.PasswordExpiryUTC = expiry
+		case "oauth_refresh_token":
+			cred.OAuthRefreshToken = value
+		case "capability[]":
+			if value == "" {
+				cred.Capabilities = nil
+				continue
+			}
+			cred.Capabilities = append(cred.Capabilities, value)
+		case "authtype":
+			cred.AuthType = value
+		case "credential":
+			cred.Credential = value
+		case "wwwauth[]":
+			// An empty value clears the list, as with every multi-valued
+			// attribute in the credential protocol.
+			if value == "" {
+				cred.WWWAuth = nil
+				continue
+			}
+			cred.WWWAuth = append(cred.WWWAuth, value)
 		}
 	}
 
@@ -51,13 +115,71 @@ func Parse(r io.Reader) (*Credential, error) {
 	return cred, nil
 }
 
+// applyURL expands a url= attribute into its components, as if they had
+// been sent individually. Attributes that follow the url= line override
+// these components; attributes that precede it must agree with them.
+func (c *Credential) applyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing url attribute: %w", err)
+	}
+	if u.Scheme == "" {
+		return fmt.Errorf("parsing url attribute %q: missing protocol", raw)
+	}
+
+	password, _ := u.User.Password()
+	components := []struct {
+		key   string
+		field *string
+		value string
+	}{
+		{"protocol", &c.Protocol, u.Scheme},
+		{"host", &c.Host, u.Host},
+		{"path", &c.Path, strings.TrimPrefix(u.Path, "/")},
+		{"username", &c.Username, u.User.Username()},
+		{"password", &c.Password, password},
+	}
+
+	for _, comp := range components {
+		if comp.value == "" {
+			continue
+		}
+		if *comp.field != "" && *comp.field != comp.value {
+			return fmt.Errorf("%w: %s differs", ErrURLConflict, comp.key)
+		}
+		*comp.field = comp.value
+	}
+
+	return nil
+}
+
+// HasCapability reports whether name was announced via capability[].
+func (c *Credential) HasCapability(name string) bool {
+	for _, capa := range c.Capabilities {
+		if capa == name {
+			return true
+		}
+	}
+	return false
+}
+
 func Write(w io.Writer, cred *Credential) error {
+	for _, capa := range cred.Capabilities {
+		if _, err := fmt.Fprintf(w, "capability[]=%s\n", capa); err != nil {
+			return fmt.Errorf("writing credential output: %w", err)
+		}
+	}
+
 	pairs := []struct{ key, val string }{
 		{"protocol", cred.Protocol},
 		{"host", cred.Host},
 		{"path", cred.Path},
 		{"username", cred.Username},
 		{"password", cred.Password},
+		{"authtype", cred.AuthType},
+		{"credential", cred.Credential},
+		{"password_expiry_utc", formatExpiry(cred.PasswordExpiryUTC)},
+		{"oauth_refresh_token", cred.OAuthRefreshToken},
 	}
 
 	for _, p := range pairs {
@@ -72,3 +194,10 @@ func Write(w io.Writer, cred *Credential) error {
 	_, err := fmt.Fprintln(w)
 	return err
 }
+
+func formatExpiry(expiry int64) string {
+	if expiry == 0 {
+		return ""
+	}
+	return strconv.FormatInt(expiry, 10)
+}
@@ -2,6 +2,8 @@ package protocol
 
 import (
 	"bytes"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -54,21 +56,171 @@ func TestParse(t *testing.T) {
 				Host:     "gitlab.com",
 			},
 		},
+		{
+			name:  "password expiry",
+			input: "protocol=https\nhost=gitlab.com\nusername=oauth2\npassword=tok\npassword_expiry_utc=1700000000\n\n",
+			want: Credential{
+				Protocol:          "https",
+				Host:              "gitlab.com",
+				Username:          "oauth2",
+				Password:          "tok",
+				PasswordExpiryUTC: 1700000000,
+			},
+		},
+		{
+			name:  "oauth refresh token",
+			input: "protocol=https\nhost=gitlab.com\nusername=oauth2\npassword=tok\noauth_refresh_token=refresh-abc\n\n",
+			want: Credential{
+				Protocol:          "https",
+				Host:              "gitlab.com",
+				Username:          "oauth2",
+				Password:          "tok",
+				OAuthRefreshToken: "refresh-abc",
+			},
+		},
+		{
+			name:  "capabilities and pre-encoded credential",
+			input: "capability[]=authtype\ncapability[]=state\nprotocol=https\nhost=github.com\nauthtype=Bearer\ncredential=github_pat_abc\n\n",
+			want: Credential{
+				Protocol:     "https",
+				Host:         "github.com",
+				Capabilities: []string{"authtype", "state"},
+				AuthType:     "Bearer",
+				Credential:   "github_pat_abc",
+			},
+		},
+		{
+			name:  "malformed password expiry ignored",
+			input: "protocol=https\nhost=gitlab.com\npassword_expiry_utc=soon\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "gitlab.com",
+			},
+		},
+		{
+			name:  "wwwauth values kept in order",
+			input: "protocol=https\nhost=git.example.com\nwwwauth[]=Bearer realm=\"x\"\nwwwauth[]=Basic realm=\"GitLab\"\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "git.example.com",
+				WWWAuth:  []string{`Bearer realm="x"`, `Basic realm="GitLab"`},
+			},
+		},
+		{
+			name:  "empty wwwauth resets the list",
+			input: "protocol=https\nhost=git.example.com\nwwwauth[]=Negotiate\nwwwauth[]=\nwwwauth[]=Basic realm=\"GitHub\"\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "git.example.com",
+				WWWAuth:  []string{`Basic realm="GitHub"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Credential
+		wantErr error
+	}{
+		{
+			name:  "url expands to components",
+			input: "url=https://alice@gitlab.example.com:8443/org1/sub/repo.git\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "gitlab.example.com:8443",
+				Path:     "org1/sub/repo.git",
+				Username: "alice",
+			},
+		},
+		{
+			name:  "url without path or user",
+			input: "url=https://github.com\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "github.com",
+			},
+		},
+		{
+			name:  "percent-encoded components are decoded",
+			input: "url=https://a%40corp@git.example.com/my%20org/repo.git\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "git.example.com",
+				Path:     "my org/repo.git",
+				Username: "a@corp",
+			},
+		},
+		{
+			name:  "later explicit keys override",
+			input: "url=https://alice@github.com/org1/repo.git\nusername=bob\npath=org2/repo.git\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "github.com",
+				Path:     "org2/repo.git",
+				Username: "bob",
+			},
+		},
+		{
+			name:  "earlier matching keys are fine",
+			input: "protocol=https\nhost=github.com\nurl=https://github.com/org1/repo.git\n\n",
+			want: Credential{
+				Protocol: "https",
+				Host:     "github.com",
+				Path:     "org1/repo.git",
+			},
+		},
+		{
+			name:    "earlier conflicting host rejected",
+			input:   "host=gitlab.com\nurl=https://github.com/org1/repo.git\n\n",
+			wantErr: ErrURLConflict,
+		},
+		{
+			name:    "earlier conflicting username rejected",
+			input:   "username=bob\nurl=https://alice@github.com/org1/repo.git\n\n",
+			wantErr: ErrURLConflict,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := Parse(strings.NewReader(tt.input))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
 			if err != nil {
 				t.Fatalf("Parse() error = %v", err)
 			}
-			if *got != tt.want {
+			if !reflect.DeepEqual(*got, tt.want) {
 				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
 			}
 		})
 	}
 }
 
+func TestParseURLMissingProtocol(t *testing.T) {
+	if _, err := Parse(strings.NewReader("url=github.com/org1/repo.git\n\n")); err == nil {
+		t.Error("Parse() should reject url without protocol")
+	}
+}
+
 func TestWrite(t *testing.T) {
 	tests := []struct {
 		name string
@@ -95,6 +247,28 @@ func TestWrite(t *testing.T) {
 			},
 			want: []string{"protocol=https", "host=gitlab.com", "username=oauth2", "password=token"},
 		},
+		{
+			name: "password expiry",
+			cred: Credential{
+				Protocol:          "https",
+				Host:              "gitlab.com",
+				Username:          "oauth2",
+				Password:          "token",
+				PasswordExpiryUTC: 1700000000,
+			},
+			want: []string{"password=token", "password_expiry_utc=1700000000"},
+		},
+		{
+			name: "pre-encoded credential",
+			cred: Credential{
+				Protocol:     "https",
+				Host:         "github.com",
+				Capabilities: []string{CapabilityAuthType},
+				AuthType:     "Bearer",
+				Credential:   "github_pat_abc",
+			},
+			want: []string{"capability[]=authtype", "authtype=Bearer", "credential=github_pat_abc"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,11 +294,13 @@ func TestWrite(t *testing.T) {
 
 func TestRoundTrip(t *testing.T) {
 	original := &Credential{
-		Protocol: "https",
-		Host:     "gitlab.com",
-		Path:     "org1/repo.git",
-		Username: "oauth2",
-		Password: "glpat-xyz789",
+		Protocol:          "https",
+		Host:              "gitlab.com",
+		Path:              "org1/repo.git",
+		Username:          "oauth2",
+		Password:          "glpat-xyz789",
+		PasswordExpiryUTC: 1700000000,
+		OAuthRefreshToken: "refresh-xyz",
 	}
 
 	var buf bytes.Buffer
@@ -137,7 +313,7 @@ func TestRoundTrip(t *testing.T) {
 		t.Fatalf("Parse() error = %v", err)
 	}
 
-	if *parsed != *original {
+	if !reflect.DeepEqual(*parsed, *original) {
 		t.Errorf("round-trip failed: got %+v, want %+v", *parsed, *original)
 	}
 }
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"strings"
+)
+
+// Realm extracts the realm parameter from a WWW-Authenticate challenge,
+// e.g. `Basic realm="GitLab"` yields "GitLab". Returns empty string if the
+// challenge carries no realm.
+func Realm(challenge string) string {
+	_, params, ok := strings.Cut(strings.TrimSpace(challenge), " ")
+	if !ok {
+		return ""
+	}
+
+	for params != "" {
+		var param string
+		param, params = nextParam(params)
+
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "realm") {
+			continue
+		}
+		return unquote(strings.TrimSpace(value))
+	}
+
+	return ""
+}
+
+// ForChallenges returns the provider identified by the realm of any of the
+// given WWW-Authenticate challenges, or nil if none is recognized.
+func ForChallenges(challenges []string) Provider {
+	for _, c := range challenges {
+		realm := Realm(c)
+		if realm == "" {
+			continue
+		}
+		for _, p := range known() {
+			if p.DetectRealm(realm) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// nextParam splits off the first comma-separated auth-param, honoring
+// quoted strings so that commas inside a realm don't end the parameter.
+func nextParam(s string) (param, rest string) {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				return strings.TrimSpace(s[:i]), s[i+1:]
+			}
+		}
+	}
+	return strings.TrimSpace(s), ""
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -13,3 +13,7 @@ func (g *Generic) TokenPrompt(namespace string) string {
 }
 
 func (g *Generic) DetectHost(_ string) bool { return true }
+
+func (g *Generic) DetectRealm(_ string) bool { return false }
+
+func (g *Generic) SupportsBearer(_ string, _ bool) bool { return false }
@@ -18,3 +18,15 @@ func (g *GitHub) TokenPrompt(namespace string) string {
 func (g *GitHub) DetectHost(host string) bool {
 	return host == "github.com" || strings.Contains(host, "github")
 }
+
+// SupportsBearer is true for fine-grained personal access tokens and OAuth
+// tokens; classic PATs keep using Basic auth.
+func (g *GitHub) SupportsBearer(token string, oauth bool) bool {
+	return oauth ||
+		strings.HasPrefix(token, "github_pat_") ||
+		strings.HasPrefix(token, "gho_")
+}
+
+func (g *GitHub) DetectRealm(realm string) bool {
+	return strings.Contains(strings.ToLower(realm), "github")
+}
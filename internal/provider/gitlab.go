@@ -18,3 +18,13 @@ func (g *GitLab) TokenPrompt(namespace string) string {
 func (g *GitLab) DetectHost(host string) bool {
 	return host == "gitlab.com" || strings.Contains(host, "gitlab")
 }
+
+// SupportsBearer is true for OAuth access tokens only; personal, project and
+// group access tokens must still be sent as Basic auth.
+func (g *GitLab) SupportsBearer(_ string, oauth bool) bool {
+	return oauth
+}
+
+func (g *GitLab) DetectRealm(realm string) bool {
+	return strings.Contains(strings.ToLower(realm), "gitlab")
+}
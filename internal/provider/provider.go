@@ -1,5 +1,15 @@
 package provider
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrChallengeMismatch is returned when the server's WWW-Authenticate
+// challenge identifies a different platform than the one configured for
+// the host.
+var ErrChallengeMismatch = errors.New("server challenge does not match configured provider")
+
 // Provider encapsulates host-specific credential behavior.
 type Provider interface {
 	// Name returns the provider identifier (e.g., "gitlab", "github").
@@ -14,15 +24,30 @@ type Provider interface {
 
 	// DetectHost returns true if this provider should handle the given host.
 	DetectHost(host string) bool
+
+	// DetectRealm returns true if a WWW-Authenticate realm (e.g. "GitLab")
+	// identifies this provider.
+	DetectRealm(realm string) bool
+
+	// SupportsBearer reports whether token can be sent as
+	// "Authorization: Bearer" instead of Basic auth with DefaultUsername.
+	// oauth is true when the token came from an OAuth flow (it has a
+	// refresh token).
+	SupportsBearer(token string, oauth bool) bool
 }
 
-// ForHost returns the appropriate provider for a given host,
-// preferring explicit configuration over auto-detection.
-func ForHost(host, configured string) Provider {
-	providers := []Provider{
+// known returns the non-generic providers in detection order.
+func known() []Provider {
+	return []Provider{
 		&GitLab{},
 		&GitHub{},
 	}
+}
+
+// ForHost returns the appropriate provider for a given host,
+// preferring explicit configuration over auto-detection.
+func ForHost(host, configured string) Provider {
+	providers := known()
 
 	if configured != "" {
 		for _, p := range providers {
@@ -40,3 +65,28 @@ func ForHost(host, configured string) Provider {
 
 	return &Generic{}
 }
+
+// ForRequest returns the provider for a credential request. An explicit
+// configuration wins, then the realm of the server's WWW-Authenticate
+// challenges, then host-name detection. If the host has a configured
+// provider and the server identifies itself as something else,
+// ErrChallengeMismatch is returned so credentials aren't sent to the
+// wrong kind of server.
+func ForRequest(host, configured string, challenges []string) (Provider, error) {
+	challenged := ForChallenges(challenges)
+
+	if configured != "" {
+		p := ForHost(host, configured)
+		if challenged != nil && p.Name() == configured && challenged.Name() != configured {
+			return nil, fmt.Errorf("%w: host %s is configured as %s but identifies as %s",
+				ErrChallengeMismatch, host, configured, challenged.Name())
+		}
+		return p, nil
+	}
+
+	if challenged != nil {
+		return challenged, nil
+	}
+
+	return ForHost(host, ""), nil
+}
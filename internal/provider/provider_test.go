@@ -1,6 +1,9 @@
 package provider
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestForHost(t *testing.T) {
 	tests := []struct {
@@ -47,3 +50,86 @@ func TestGenericDefaults(t *testing.T) {
 		t.Errorf("DefaultUsername() = %q, want %q", g.DefaultUsername(), "")
 	}
 }
+
+func TestSupportsBearer(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Provider
+		token string
+		oauth bool
+		want  bool
+	}{
+		{name: "github fine-grained PAT", p: &GitHub{}, token: "github_pat_11ABC", want: true},
+		{name: "github OAuth token", p: &GitHub{}, token: "gho_abc", want: true},
+		{name: "github classic PAT", p: &GitHub{}, token: "ghp_abc", want: false},
+		{name: "gitlab PAT", p: &GitLab{}, token: "glpat-abc", want: false},
+		{name: "gitlab OAuth token", p: &GitLab{}, token: "abc123", oauth: true, want: true},
+		{name: "generic never", p: &Generic{}, token: "anything", oauth: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.SupportsBearer(tt.token, tt.oauth); got != tt.want {
+				t.Errorf("SupportsBearer(%q, %v) = %v, want %v", tt.token, tt.oauth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRealm(t *testing.T) {
+	tests := []struct {
+		challenge string
+		want      string
+	}{
+		{challenge: `Basic realm="GitLab"`, want: "GitLab"},
+		{challenge: `Basic realm="GitHub"`, want: "GitHub"},
+		{challenge: `Bearer error="invalid_token", realm="Gitea, Inc"`, want: "Gitea, Inc"},
+		{challenge: `Basic REALM=plain`, want: "plain"},
+		{challenge: `Basic realm="a \"quoted\" name"`, want: `a "quoted" name`},
+		{challenge: `Negotiate`, want: ""},
+		{challenge: ``, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Realm(tt.challenge); got != tt.want {
+			t.Errorf("Realm(%q) = %q, want %q", tt.challenge, got, tt.want)
+		}
+	}
+}
+
+func TestForRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		host       string
+		configured string
+		challenges []string
+		wantName   string
+		wantErr    error
+	}{
+		{name: "realm identifies self-hosted gitlab", host: "git.corp.example", challenges: []string{`Basic realm="GitLab"`}, wantName: "gitlab"},
+		{name: "realm overrides host heuristic", host: "gitlab-mirror.example", challenges: []string{`Basic realm="GitHub"`}, wantName: "github"},
+		{name: "unknown realm falls back to host detection", host: "github.example", challenges: []string{`Basic realm="Restricted"`}, wantName: "github"},
+		{name: "no challenges", host: "git.example.com", wantName: "generic"},
+		{name: "configured matches challenge", host: "git.corp.example", configured: "gitlab", challenges: []string{`Basic realm="GitLab"`}, wantName: "gitlab"},
+		{name: "configured without recognizable challenge", host: "git.corp.example", configured: "github", challenges: []string{`Negotiate`}, wantName: "github"},
+		{name: "configured mismatches challenge", host: "git.corp.example", configured: "github", challenges: []string{`Basic realm="GitLab"`}, wantErr: ErrChallengeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ForRequest(tt.host, tt.configured, tt.challenges)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ForRequest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ForRequest() error = %v", err)
+			}
+			if p.Name() != tt.wantName {
+				t.Errorf("ForRequest().Name() = %q, want %q", p.Name(), tt.wantName)
+			}
+		})
+	}
+}
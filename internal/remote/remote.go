@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Remote is a configured git remote URL, split into host and path.
+type Remote struct {
+	Name string
+	Host string
+	Path string
+}
+
+// List returns the remotes of the repository in dir (the current
+// directory if empty), including push URLs. URLs are read with "git remote
+// get-url", so url.<base>.insteadOf shorthands are expanded as git expands
+// them. Git itself honors GIT_DIR, so this also works when the helper runs
+// outside the work tree.
+func List(dir string) ([]Remote, error) {
+	out, err := git(dir, "remote")
+	if err != nil {
+		return nil, fmt.Errorf("listing git remotes: %w", err)
+	}
+
+	var remotes []Remote
+	for _, name := range strings.Fields(out) {
+		seen := make(map[string]bool)
+		for _, args := range [][]string{{"remote", "get-url", "--all", name}, {"remote", "get-url", "--push", "--all", name}} {
+			urls, err := git(dir, args...)
+			if err != nil {
+				return nil, fmt.Errorf("reading URLs of remote %s: %w", name, err)
+			}
+			for _, u := range strings.Fields(urls) {
+				if seen[u] {
+					continue
+				}
+				seen[u] = true
+
+				host, path, ok := ParseURL(u)
+				if !ok {
+					continue
+				}
+				remotes = append(remotes, Remote{Name: name, Host: host, Path: path})
+			}
+		}
+	}
+
+	return remotes, nil
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	cmd := exec.Command("git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// ParseURL extracts host and path from a remote URL in any of the forms
+// git accepts: "https://host/path", "ssh://user@host:port/path" and the
+// scp-like "user@host:path". Local paths and file:// URLs return false.
+func ParseURL(raw string) (host, path string, ok bool) {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return "", "", false
+		}
+		host = u.Host
+		// ssh remotes use port 22 by convention; the HTTPS host doesn't have it.
+		if u.Scheme == "ssh" || u.Scheme == "git+ssh" {
+			host = u.Hostname()
+		}
+		return host, strings.TrimPrefix(u.Path, "/"), true
+	}
+
+	// scp-like syntax: [user@]host:path, where host has no slash.
+	hostPart, path, found := strings.Cut(raw, ":")
+	if !found || strings.Contains(hostPart, "/") {
+		return "", "", false
+	}
+	if _, h, ok := strings.Cut(hostPart, "@"); ok {
+		hostPart = h
+	}
+	if hostPart == "" {
+		return "", "", false
+	}
+	return hostPart, strings.TrimPrefix(path, "/"), true
+}
+
+// ErrAmbiguous is returned by InferPath when remotes on the host lead to
+// different namespaces: git doesn't tell a helper which remote it is
+// using, so none of them can be picked safely.
+var ErrAmbiguous = errors.New("remotes resolve to different namespaces")
+
+// InferPath looks for a remote of the repository in dir whose host
+// satisfies matches and returns its path along with a description of where
+// it came from. If several remotes match, they must all resolve to the same
+// namespace; "origin" is then preferred.
+func InferPath(dir string, matches func(host string) bool, namespace func(path string) string) (path, source string, err error) {
+	remotes, err := List(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	var found *Remote
+	for i, r := range remotes {
+		if !matches(r.Host) {
+			continue
+		}
+		if found != nil && namespace(r.Path) != namespace(found.Path) {
+			return "", "", fmt.Errorf("%w: %q (%s) and %q (%s)", ErrAmbiguous, found.Name, namespace(found.Path), r.Name, namespace(r.Path))
+		}
+		if found == nil || (r.Name == "origin" && found.Name != "origin") {
+			found = &remotes[i]
+		}
+	}
+	if found == nil {
+		return "", "", nil
+	}
+
+	where := "current directory"
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		where = "GIT_DIR=" + gitDir
+	} else if dir != "" {
+		where = dir
+	}
+
+	return found.Path, fmt.Sprintf("remote %q in %s", found.Name, where), nil
+}
@@ -0,0 +1,97 @@
+package remote
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		wantHost string
+		wantPath string
+		wantOK   bool
+	}{
+		{raw: "https://github.com/acme/web.git", wantHost: "github.com", wantPath: "acme/web.git", wantOK: true},
+		{raw: "https://alice@gitlab.example.com:8443/org/sub/x.git", wantHost: "gitlab.example.com:8443", wantPath: "org/sub/x.git", wantOK: true},
+		{raw: "ssh://git@gitlab.com:22/org/repo.git", wantHost: "gitlab.com", wantPath: "org/repo.git", wantOK: true},
+		{raw: "git@github.com:acme/web.git", wantHost: "github.com", wantPath: "acme/web.git", wantOK: true},
+		{raw: "gitlab.com:/org/repo.git", wantHost: "gitlab.com", wantPath: "org/repo.git", wantOK: true},
+		{raw: "/srv/git/repo.git", wantOK: false},
+		{raw: "../relative/repo", wantOK: false},
+		{raw: "file:///srv/git/repo.git", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		host, path, ok := ParseURL(tt.raw)
+		if ok != tt.wantOK || host != tt.wantHost || path != tt.wantPath {
+			t.Errorf("ParseURL(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.raw, host, path, ok, tt.wantHost, tt.wantPath, tt.wantOK)
+		}
+	}
+}
+
+func TestInferPath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "upstream", "https://github.com/upstream/web.git"},
+		{"remote", "add", "origin", "git@github.com:acme/web.git"},
+		{"remote", "add", "mirror", "https://gitlab.com/org/sub/web.git"},
+		{"config", "url.https://bitbucket.org/.insteadOf", "bb:"},
+		{"remote", "add", "short", "bb:team/web.git"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	// Restore any GIT_DIR from the environment running the tests afterwards.
+	t.Setenv("GIT_DIR", "")
+	os.Unsetenv("GIT_DIR")
+
+	onHost := func(want string) func(string) bool {
+		return func(host string) bool { return host == want }
+	}
+	org := func(path string) string { org, _, _ := strings.Cut(path, "/"); return org }
+	host := func(string) string { return "" }
+
+	// A fork and its upstream need different credentials.
+	if path, _, err := InferPath(dir, onHost("github.com"), org); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("InferPath(github.com) = %q, %v, want ErrAmbiguous", path, err)
+	}
+
+	path, source, err := InferPath(dir, onHost("github.com"), host)
+	if err != nil {
+		t.Fatalf("InferPath() error = %v", err)
+	}
+	if path != "acme/web.git" {
+		t.Errorf("InferPath(github.com) path = %q, want origin's %q (source %s)", path, "acme/web.git", source)
+	}
+
+	path, _, err = InferPath(dir, onHost("gitlab.com"), org)
+	if err != nil {
+		t.Fatalf("InferPath() error = %v", err)
+	}
+	if path != "org/sub/web.git" {
+		t.Errorf("InferPath(gitlab.com) path = %q, want %q", path, "org/sub/web.git")
+	}
+
+	path, _, err = InferPath(dir, onHost("bitbucket.org"), org)
+	if err != nil || path != "team/web.git" {
+		t.Errorf("InferPath(bitbucket.org) = (%q, %v), want the insteadOf remote", path, err)
+	}
+
+	path, _, err = InferPath(dir, onHost("codeberg.org"), org)
+	if err != nil || path != "" {
+		t.Errorf("InferPath(codeberg.org) = (%q, %v), want no match", path, err)
+	}
+}
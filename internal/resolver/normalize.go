@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultPorts are stripped from hosts so that "gitlab.com:443" and
+// "gitlab.com" resolve to the same namespace.
+var defaultPorts = map[string]string{
+	"https": "443",
+	"http":  "80",
+	"ssh":   "22",
+	"git":   "9418",
+}
+
+// Normalize canonicalizes a host and path before namespace resolution:
+// hosts are lowercased with any userinfo and the protocol's default port
+// removed; paths have empty, "." and ".." segments dropped, segments
+// URL-decoded (except those with an encoded "/", which git doesn't split
+// on either and are kept as they are), a GitLab "/-/" suffix (e.g.
+// "/-/tree/main") dropped and a ".git" suffix removed from the repository
+// segment.
+func Normalize(protocol, host, path string) (string, string) {
+	return normalizeHost(protocol, host), normalizePath(path)
+}
+
+func normalizeHost(protocol, host string) string {
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if port, ok := defaultPorts[strings.ToLower(protocol)]; ok {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+
+	return host
+}
+
+func normalizePath(path string) string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		// GitLab puts "-" between the project path and UI/API routes.
+		if seg == "-" {
+			break
+		}
+		if decoded, err := url.PathUnescape(seg); err == nil && !strings.Contains(decoded, "/") {
+			seg = decoded
+		}
+		// Dot segments would let a path climb into another namespace.
+		if seg == "." || seg == ".." {
+			continue
+		}
+		segments = append(segments, seg)
+
+		// Anything after "repo.git/" is a git or web route, not a namespace.
+		if strings.HasSuffix(seg, ".git") {
+			break
+		}
+	}
+
+	if n := len(segments); n > 0 {
+		segments[n-1] = strings.TrimSuffix(segments[n-1], ".git")
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,69 @@
+package resolver
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		host     string
+		path     string
+		wantHost string
+		wantPath string
+	}{
+		{name: "github plain", protocol: "https", host: "github.com", path: "org/repo.git", wantHost: "github.com", wantPath: "org/repo"},
+		{name: "host case", protocol: "https", host: "GitLab.com", path: "org/repo.git", wantHost: "gitlab.com", wantPath: "org/repo"},
+		{name: "default https port", protocol: "https", host: "gitlab.com:443", path: "org/repo", wantHost: "gitlab.com", wantPath: "org/repo"},
+		{name: "default http port", protocol: "http", host: "git.example.com:80", path: "org/repo", wantHost: "git.example.com", wantPath: "org/repo"},
+		{name: "non-default port kept", protocol: "https", host: "git.example.com:8443", path: "org/repo", wantHost: "git.example.com:8443", wantPath: "org/repo"},
+		{name: "https port on http kept", protocol: "http", host: "git.example.com:443", path: "org/repo", wantHost: "git.example.com:443", wantPath: "org/repo"},
+		{name: "userinfo in host", protocol: "https", host: "alice@GitHub.com", path: "org/repo", wantHost: "github.com", wantPath: "org/repo"},
+		{name: "trailing dot in host", protocol: "https", host: "github.com.", path: "org/repo", wantHost: "github.com", wantPath: "org/repo"},
+		{name: "collapsed slashes", protocol: "https", host: "gitlab.com", path: "//org//repo", wantHost: "gitlab.com", wantPath: "org/repo"},
+		{name: "trailing slash", protocol: "https", host: "gitlab.com", path: "org/repo/", wantHost: "gitlab.com", wantPath: "org/repo"},
+		{name: "encoded segment", protocol: "https", host: "gitlab.com", path: "my%20org/repo.git", wantHost: "gitlab.com", wantPath: "my org/repo"},
+		{name: "gitlab subgroups", protocol: "https", host: "gitlab.com", path: "org/sub/project.git", wantHost: "gitlab.com", wantPath: "org/sub/project"},
+		{name: "gitlab dash route", protocol: "https", host: "gitlab.com", path: "org/sub/project/-/tree/main", wantHost: "gitlab.com", wantPath: "org/sub/project"},
+		{name: "route after .git", protocol: "https", host: "github.com", path: "org/repo.git/info/refs", wantHost: "github.com", wantPath: "org/repo"},
+		{name: "bitbucket cloud", protocol: "https", host: "bitbucket.org", path: "workspace/repo.git", wantHost: "bitbucket.org", wantPath: "workspace/repo"},
+		{name: "bitbucket server personal repo", protocol: "https", host: "bitbucket.corp.example", path: "scm/~alice/repo.git", wantHost: "bitbucket.corp.example", wantPath: "scm/~alice/repo"},
+		{name: "bitbucket server encoded tilde", protocol: "https", host: "bitbucket.corp.example", path: "scm/%7Ealice/repo.git", wantHost: "bitbucket.corp.example", wantPath: "scm/~alice/repo"},
+		{name: "azure devops", protocol: "https", host: "dev.azure.com", path: "contoso/Project%20X/_git/app", wantHost: "dev.azure.com", wantPath: "contoso/Project X/_git/app"},
+		{name: "azure devops legacy host", protocol: "https", host: "Contoso.VisualStudio.com", path: "DefaultCollection/proj/_git/app", wantHost: "contoso.visualstudio.com", wantPath: "DefaultCollection/proj/_git/app"},
+		{name: "encoded slash kept", protocol: "https", host: "gitlab.com", path: "..%2Fgithub.com%2Fx/repo", wantHost: "gitlab.com", wantPath: "..%2Fgithub.com%2Fx/repo"},
+		{name: "dot segments dropped", protocol: "https", host: "gitlab.com", path: "../github.com/./x/repo.git", wantHost: "gitlab.com", wantPath: "github.com/x/repo"},
+		{name: "encoded dot segments dropped", protocol: "https", host: "gitlab.com", path: "%2E%2E/org/%2e/repo", wantHost: "gitlab.com", wantPath: "org/repo"},
+		{name: "empty path", protocol: "https", host: "github.com", path: "", wantHost: "github.com", wantPath: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, path := Normalize(tt.protocol, tt.host, tt.path)
+			if host != tt.wantHost || path != tt.wantPath {
+				t.Errorf("Normalize(%q, %q, %q) = (%q, %q), want (%q, %q)",
+					tt.protocol, tt.host, tt.path, host, path, tt.wantHost, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestResolveNormalized(t *testing.T) {
+	// Spellings of the same repository must land in the same namespace.
+	tests := []struct {
+		host string
+		path string
+	}{
+		{"gitlab.com", "org/repo.git"},
+		{"GitLab.com", "org/repo"},
+		{"gitlab.com:443", "/org/repo.git"},
+		{"gitlab.com", "//org//repo/"},
+		{"gitlab.com", "org/repo/-/merge_requests/1"},
+	}
+
+	for _, tt := range tests {
+		host, path := Normalize("https", tt.host, tt.path)
+		if got := Resolve(host, path); got != "gitlab.com/org" {
+			t.Errorf("Resolve(Normalize(%q, %q)) = %q, want %q", tt.host, tt.path, got, "gitlab.com/org")
+		}
+	}
+}
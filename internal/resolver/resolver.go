@@ -8,17 +8,61 @@ import (
 // For "gitlab.com" + "org1/project/repo.git" it returns "gitlab.com/org1".
 // For self-hosted instances without a path prefix, it returns just the host.
 func Resolve(host, path string) string {
+	return ResolveDepth(host, path, 1)
+}
+
+// ResolveDepth derives a namespace from a host and up to depth leading
+// path segments, so that GitLab subgroups can get their own namespace:
+// "gitlab.com" + "company/team-a/repo.git" at depth 2 returns
+// "gitlab.com/company/team-a". The repository segment itself is only used
+// when the path has no other segments. Depths below 1 are treated as 1.
+func ResolveDepth(host, path string, depth int) string {
 	if path == "" {
 		return host
 	}
+	if depth < 1 {
+		depth = 1
+	}
 
 	path = strings.TrimPrefix(path, "/")
-	firstSegment, _, _ := strings.Cut(path, "/")
-	firstSegment = strings.TrimSuffix(firstSegment, ".git")
+	segments := strings.Split(path, "/")
+	if len(segments) > 1 {
+		segments = segments[:len(segments)-1]
+	}
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], ".git")
 
-	if firstSegment == "" {
+	namespace := strings.Join(segments, "/")
+	if namespace == "" {
 		return host
 	}
 
-	return host + "/" + firstSegment
+	return host + "/" + namespace
+}
+
+// Candidates returns the namespaces to try for a host and path, from the
+// most specific (ResolveDepth at depth) up to the bare host:
+// "gitlab.com" + "org/sub/subsub/repo.git" at depth 3 yields
+// ["gitlab.com/org/sub/subsub", "gitlab.com/org/sub", "gitlab.com/org", "gitlab.com"].
+func Candidates(host, path string, depth int) []string {
+	if depth < 1 {
+		depth = 1
+	}
+
+	var chain []string
+	for d := depth; d >= 1; d-- {
+		ns := ResolveDepth(host, path, d)
+		if len(chain) > 0 && chain[len(chain)-1] == ns {
+			continue
+		}
+		chain = append(chain, ns)
+	}
+
+	if chain[len(chain)-1] != host {
+		chain = append(chain, host)
+	}
+
+	return chain
 }
@@ -1,6 +1,9 @@
 package resolver
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestResolve(t *testing.T) {
 	tests := []struct {
@@ -74,3 +77,78 @@ func TestResolve(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		depth int
+		want  string
+	}{
+		{name: "subgroup team-a", path: "company/team-a/service/repo.git", depth: 2, want: "gitlab.company.com/company/team-a"},
+		{name: "subgroup team-b", path: "company/team-b/repo.git", depth: 2, want: "gitlab.company.com/company/team-b"},
+		{name: "repo directly under group", path: "company/repo.git", depth: 2, want: "gitlab.company.com/company"},
+		{name: "depth larger than path", path: "company/team-a/repo.git", depth: 5, want: "gitlab.company.com/company/team-a"},
+		{name: "depth 1 matches Resolve", path: "company/team-a/repo.git", depth: 1, want: "gitlab.company.com/company"},
+		{name: "zero depth treated as 1", path: "company/team-a/repo.git", depth: 0, want: "gitlab.company.com/company"},
+		{name: "single segment", path: "repo.git", depth: 3, want: "gitlab.company.com/repo"},
+		{name: "empty path", path: "", depth: 2, want: "gitlab.company.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveDepth("gitlab.company.com", tt.path, tt.depth)
+			if got != tt.want {
+				t.Errorf("ResolveDepth(%q, %d) = %q, want %q", tt.path, tt.depth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		host  string
+		path  string
+		depth int
+		want  []string
+	}{
+		{
+			name:  "walks up to the host",
+			host:  "gitlab.com",
+			path:  "org/sub/subsub/repo.git",
+			depth: 3,
+			want:  []string{"gitlab.com/org/sub/subsub", "gitlab.com/org/sub", "gitlab.com/org", "gitlab.com"},
+		},
+		{
+			name:  "default depth",
+			host:  "github.com",
+			path:  "org1/repo.git",
+			depth: 1,
+			want:  []string{"github.com/org1", "github.com"},
+		},
+		{
+			name:  "shallow path deduplicates levels",
+			host:  "gitlab.com",
+			path:  "org/repo.git",
+			depth: 3,
+			want:  []string{"gitlab.com/org", "gitlab.com"},
+		},
+		{
+			name:  "empty path is just the host",
+			host:  "gitlab.com",
+			path:  "",
+			depth: 2,
+			want:  []string{"gitlab.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Candidates(tt.host, tt.path, tt.depth)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates(%q, %q, %d) = %q, want %q", tt.host, tt.path, tt.depth, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rule maps repositories whose "host/path" matches a pattern to a fixed
+// namespace, so e.g. every github.com/acme-* org can share one credential.
+// Exactly one of Glob or Regex is expected to be set.
+type Rule struct {
+	// Glob is matched with path.Match against the host followed by any
+	// number of leading path segments, so "github.com/acme-*" matches
+	// "github.com/acme-web/site.git".
+	Glob string
+
+	// Regex is matched (unanchored) against "host/path" with the ".git"
+	// suffix removed.
+	Regex *regexp.Regexp
+
+	Namespace string
+}
+
+// Matches reports whether the rule applies to host and path. A malformed
+// Glob never matches; config.Load rejects them up front.
+func (r Rule) Matches(host, repoPath string) bool {
+	target := ruleTarget(host, repoPath)
+
+	if r.Regex != nil {
+		return r.Regex.MatchString(target)
+	}
+
+	if r.Glob == "" {
+		return false
+	}
+	return MatchGlob(r.Glob, target)
+}
+
+// MatchGlob reports whether pattern matches name, or any of its parents:
+// "gitlab.com/*" matches "gitlab.com/org" and "gitlab.com/org/team" alike,
+// although "*" doesn't match "/". Malformed patterns never match.
+func MatchGlob(pattern, name string) bool {
+	segments := strings.Split(name, "/")
+	for i := 1; i <= len(segments); i++ {
+		if ok, _ := path.Match(pattern, strings.Join(segments[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the namespace of the first rule that applies to host and
+// path, in order.
+func Match(rules []Rule, host, repoPath string) (string, bool) {
+	for _, r := range rules {
+		if r.Matches(host, repoPath) {
+			return r.Namespace, true
+		}
+	}
+	return "", false
+}
+
+func ruleTarget(host, repoPath string) string {
+	repoPath = strings.Trim(repoPath, "/")
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	if repoPath == "" {
+		return host
+	}
+	return host + "/" + repoPath
+}
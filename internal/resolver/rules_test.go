@@ -0,0 +1,40 @@
+package resolver
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	rules := []Rule{
+		{Glob: "gitlab.com/acme/legacy-*", Namespace: "acme-legacy"},
+		{Glob: "github.com/acme-*", Namespace: "acme"},
+		{Regex: regexp.MustCompile(`^gitlab\.com/acme(/|$)`), Namespace: "acme-gitlab"},
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{name: "glob on org", host: "github.com", path: "acme-web/site.git", want: "acme", wantOK: true},
+		{name: "second org shares namespace", host: "github.com", path: "acme-infra/tf", want: "acme", wantOK: true},
+		{name: "glob on project", host: "gitlab.com", path: "acme/legacy-billing/app.git", want: "acme-legacy", wantOK: true},
+		{name: "first rule wins over later regex", host: "gitlab.com", path: "acme/legacy-x.git", want: "acme-legacy", wantOK: true},
+		{name: "regex", host: "gitlab.com", path: "acme/platform/api.git", want: "acme-gitlab", wantOK: true},
+		{name: "regex does not match prefix of other org", host: "gitlab.com", path: "acmecorp/api.git", wantOK: false},
+		{name: "glob does not cross org boundary", host: "github.com", path: "other/acme-web.git", wantOK: false},
+		{name: "no path", host: "github.com", path: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Match(rules, tt.host, tt.path)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Match(%q, %q) = (%q, %v), want (%q, %v)", tt.host, tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// bwSessionEnv is the variable bw reads the session key of an unlocked
+// vault from.
+const bwSessionEnv = "BW_SESSION"
+
+// Bitwarden item and custom field types, as used by the bw CLI.
+const (
+	bwItemTypeLogin   = 1
+	bwFieldTypeText   = 0
+	bwFieldTypeHidden = 1
+)
+
+// BitwardenStore keeps each namespace as a Login item titled like the
+// 1Password items, shelling out to the bw CLI. The vault must be unlocked
+// with the session key exported in $BW_SESSION.
+type BitwardenStore struct {
+	folder       string
+	organization string
+	collection   string
+
+	// run executes bw with the given arguments; replaced in tests.
+	run func(args ...string) ([]byte, error)
+
+	// unlocked caches a successful status check for the process lifetime.
+	unlocked bool
+
+	// ids caches the resolved folder, organization and collection IDs.
+	ids *bwIDs
+}
+
+type bwIDs struct {
+	folder, organization, collection string
+}
+
+// NewBitwardenStore returns a store keeping items in folder and, if
+// organization is set, shared with the organization's collection. Each may
+// be given as a name or an ID; empty means none.
+func NewBitwardenStore(folder, organization, collection string) *BitwardenStore {
+	return &BitwardenStore{
+		folder:       folder,
+		organization: organization,
+		collection:   collection,
+		run:          runBW,
+	}
+}
+
+func (b *BitwardenStore) Name() string {
+	return "bitwarden"
+}
+
+func (b *BitwardenStore) Get(namespace string) (*Credential, error) {
+	raw, err := b.findItem(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("bitwarden get %q: %w", namespace, err)
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	data, _ := json.Marshal(raw)
+	var item bwItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("parsing bitwarden item: %w", err)
+	}
+	return item.credential()
+}
+
+func (b *BitwardenStore) Store(namespace string, cred *Credential) error {
+	raw, err := b.findItem(namespace)
+	if err != nil {
+		return fmt.Errorf("bitwarden store %q: %w", namespace, err)
+	}
+
+	if raw == nil {
+		item, err := b.newItem(namespace)
+		if err != nil {
+			return fmt.Errorf("bitwarden store %q: %w", namespace, err)
+		}
+		setBWCredential(item, cred)
+		if _, err := b.run("create", "item", encodeBWItem(item)); err != nil {
+			return fmt.Errorf("bitwarden create item: %w", err)
+		}
+		return nil
+	}
+
+	// Edit the item as returned by bw so fields we don't manage (notes,
+	// URIs, favorite) survive: bw edit replaces the whole item.
+	id, _ := raw["id"].(string)
+	setBWCredential(raw, cred)
+	if _, err := b.run("edit", "item", id, encodeBWItem(raw)); err != nil {
+		return fmt.Errorf("bitwarden edit item: %w", err)
+	}
+	return nil
+}
+
+func (b *BitwardenStore) Erase(namespace string) error {
+	raw, err := b.findItem(namespace)
+	if err != nil {
+		return fmt.Errorf("bitwarden erase %q: %w", namespace, err)
+	}
+	if raw == nil {
+		return nil
+	}
+
+	id, _ := raw["id"].(string)
+	if _, err := b.run("delete", "item", id); err != nil {
+		return fmt.Errorf("bitwarden erase %q: %w", namespace, err)
+	}
+	return nil
+}
+
+type bwStatus struct {
+	Status string `json:"status"`
+}
+
+// checkUnlocked fails with instructions unless bw reports an unlocked vault.
+func (b *BitwardenStore) checkUnlocked() error {
+	if b.unlocked {
+		return nil
+	}
+
+	out, err := b.run("status")
+	if err != nil {
+		return err
+	}
+	var status bwStatus
+	if err := json.Unmarshal(out, &status); err != nil {
+		return fmt.Errorf("parsing bw status: %w", err)
+	}
+
+	switch status.Status {
+	case "unlocked":
+		b.unlocked = true
+		return nil
+	case "unauthenticated":
+		return errors.New("not logged in to bitwarden; run: bw login")
+	case "locked":
+		if os.Getenv(bwSessionEnv) != "" {
+			return errors.New("bitwarden vault is locked: " + bwSessionEnv + " is set but not valid (expired?); run: export " + bwSessionEnv + "=$(bw unlock --raw)")
+		}
+		return errors.New("bitwarden vault is locked; run: export " + bwSessionEnv + "=$(bw unlock --raw)")
+	default:
+		return fmt.Errorf("unexpected bw status %q", status.Status)
+	}
+}
+
+type bwItem struct {
+	Fields []bwField `json:"fields"`
+	Login  *bwLogin  `json:"login"`
+}
+
+type bwField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+type bwLogin struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (i *bwItem) credential() (*Credential, error) {
+	cred := &Credential{}
+	if i.Login != nil {
+		cred.Username = i.Login.Username
+		cred.Password = i.Login.Password
+	}
+
+	for _, f := range i.Fields {
+		switch f.Name {
+		case fieldExpiry:
+			if f.Value == "" {
+				continue
+			}
+			expiry, err := strconv.ParseInt(f.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing bitwarden %s field: %w", fieldExpiry, err)
+			}
+			cred.PasswordExpiryUTC = expiry
+		case fieldRefreshToken:
+			cred.OAuthRefreshToken = f.Value
+		case fieldAuthType:
+			cred.AuthType = f.Value
+		default:
+			setMetadataField(cred, f.Name, f.Value)
+		}
+	}
+
+	if cred.Username == "" && cred.Password == "" {
+		return nil, ErrNotFound
+	}
+	return cred, nil
+}
+
+// findItem returns the namespace's item with every property bw returned,
+// or nil if there is none.
+func (b *BitwardenStore) findItem(namespace string) (map[string]any, error) {
+	items, err := b.listItems(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []map[string]any
+	if err := json.Unmarshal(items, &found); err != nil {
+		return nil, fmt.Errorf("parsing bw items: %w", err)
+	}
+	title := itemTitle(namespace)
+	for _, item := range found {
+		if name, _ := item["name"].(string); name == title {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// listItems returns the items matching the namespace title within the
+// configured folder, organization and collection. bw's search is fuzzy, so
+// callers still compare names exactly.
+func (b *BitwardenStore) listItems(namespace string) ([]byte, error) {
+	if err := b.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	ids, err := b.resolveIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"list", "items", "--search", itemTitle(namespace)}
+	if ids.folder != "" {
+		args = append(args, "--folderid", ids.folder)
+	}
+	if ids.organization != "" {
+		args = append(args, "--organizationid", ids.organization)
+	}
+	if ids.collection != "" {
+		args = append(args, "--collectionid", ids.collection)
+	}
+
+	return b.run(args...)
+}
+
+func (b *BitwardenStore) newItem(namespace string) (map[string]any, error) {
+	ids, err := b.resolveIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	item := map[string]any{
+		"type":  bwItemTypeLogin,
+		"name":  itemTitle(namespace),
+		"login": map[string]any{},
+	}
+	if ids.folder != "" {
+		item["folderId"] = ids.folder
+	}
+	if ids.organization != "" {
+		item["organizationId"] = ids.organization
+	}
+	if ids.collection != "" {
+		item["collectionIds"] = []string{ids.collection}
+	}
+	return item, nil
+}
+
+// resolveIDs maps the configured folder, organization and collection names
+// to IDs.
+func (b *BitwardenStore) resolveIDs() (*bwIDs, error) {
+	if b.ids != nil {
+		return b.ids, nil
+	}
+
+	ids := &bwIDs{}
+	var err error
+	if b.folder != "" {
+		if ids.folder, err = b.lookupID("folder", b.folder, "list", "folders"); err != nil {
+			return nil, err
+		}
+	}
+	if b.organization != "" {
+		if ids.organization, err = b.lookupID("organization", b.organization, "list", "organizations"); err != nil {
+			return nil, err
+		}
+	}
+	if b.collection != "" {
+		if ids.organization == "" {
+			return nil, errors.New("bitwarden collection requires an organization")
+		}
+		if ids.collection, err = b.lookupID("collection", b.collection, "list", "collections", "--organizationid", ids.organization); err != nil {
+			return nil, err
+		}
+	}
+
+	b.ids = ids
+	return ids, nil
+}
+
+// lookupID runs a bw list command and returns the ID of the entry whose
+// name or ID is nameOrID.
+func (b *BitwardenStore) lookupID(kind, nameOrID string, args ...string) (string, error) {
+	out, err := b.run(args...)
+	if err != nil {
+		return "", err
+	}
+
+	var entries []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return "", fmt.Errorf("parsing bw %s list: %w", kind, err)
+	}
+	for _, e := range entries {
+		if e.ID == nameOrID || e.Name == nameOrID {
+			return e.ID, nil
+		}
+	}
+	return "", fmt.Errorf("bitwarden %s %q not found", kind, nameOrID)
+}
+
+// setBWCredential writes cred into a bw item, replacing our custom fields
+// and keeping any others. Empty optional values remove their field.
+func setBWCredential(item map[string]any, cred *Credential) {
+	login, _ := item["login"].(map[string]any)
+	if login == nil {
+		login = map[string]any{}
+	}
+	login["username"] = cred.Username
+	login["password"] = cred.Password
+	item["login"] = login
+
+	var fields []any
+	existing, _ := item["fields"].([]any)
+	for _, f := range existing {
+		m, _ := f.(map[string]any)
+		switch name, _ := m["name"].(string); name {
+		case fieldExpiry, fieldRefreshToken, fieldAuthType:
+			continue
+		default:
+			if isMetadataField(name) {
+				continue
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	ours := []bwField{
+		{fieldExpiry, formatUnix(cred.PasswordExpiryUTC), bwFieldTypeText},
+		{fieldRefreshToken, cred.OAuthRefreshToken, bwFieldTypeHidden},
+		{fieldAuthType, cred.AuthType, bwFieldTypeText},
+	}
+	for _, f := range metadataFields(cred) {
+		ours = append(ours, bwField{f.name, f.value, bwFieldTypeText})
+	}
+	for _, f := range ours {
+		if f.Value != "" {
+			fields = append(fields, map[string]any{"name": f.Name, "value": f.Value, "type": f.Type})
+		}
+	}
+	item["fields"] = fields
+}
+
+// encodeBWItem returns item in the base64 JSON encoding bw create and
+// bw edit expect (what `bw encode` produces).
+func encodeBWItem(item map[string]any) string {
+	data, _ := json.Marshal(item)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func runBW(args ...string) ([]byte, error) {
+	cmd := exec.Command("bw", append([]string{"--nointeraction"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeBW is an in-memory stand-in for the bw CLI.
+type fakeBW struct {
+	status  string
+	folders []map[string]any
+	items   map[string]map[string]any
+	next    int
+	calls   [][]string
+}
+
+func newFakeBW() *fakeBW {
+	return &fakeBW{
+		status:  "unlocked",
+		folders: []map[string]any{{"id": "f1", "name": "Git"}},
+		items:   make(map[string]map[string]any),
+	}
+}
+
+func (f *fakeBW) run(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, args)
+
+	switch strings.Join(args[:min(2, len(args))], " ") {
+	case "status":
+		return json.Marshal(map[string]string{"status": f.status})
+	case "list folders":
+		return json.Marshal(f.folders)
+	case "list items":
+		search := args[3]
+		var found []map[string]any
+		for _, item := range f.items {
+			if strings.Contains(item["name"].(string), search) {
+				found = append(found, item)
+			}
+		}
+		return json.Marshal(found)
+	case "create item":
+		item, err := decodeFakeBWItem(args[2])
+		if err != nil {
+			return nil, err
+		}
+		f.next++
+		item["id"] = fmt.Sprintf("id-%d", f.next)
+		f.items[item["id"].(string)] = item
+		return json.Marshal(item)
+	case "edit item":
+		if _, ok := f.items[args[2]]; !ok {
+			return nil, errors.New("Not found.")
+		}
+		item, err := decodeFakeBWItem(args[3])
+		if err != nil {
+			return nil, err
+		}
+		f.items[args[2]] = item
+		return json.Marshal(item)
+	case "delete item":
+		delete(f.items, args[2])
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected bw %v", args)
+}
+
+func decodeFakeBWItem(encoded string) (map[string]any, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var item map[string]any
+	return item, json.Unmarshal(data, &item)
+}
+
+func TestBitwardenStoreRoundTrip(t *testing.T) {
+	fake := newFakeBW()
+	b := NewBitwardenStore("Git", "", "")
+	b.run = fake.run
+
+	if _, err := b.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000, OAuthRefreshToken: "rt"}
+	if err := b.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	item := fake.items["id-1"]
+	if item["name"] != "git-credentials-org: gitlab.com/org1" {
+		t.Errorf("item name = %v, want 1Password-compatible title", item["name"])
+	}
+	if item["folderId"] != "f1" {
+		t.Errorf("item folderId = %v, want f1", item["folderId"])
+	}
+
+	got, err := b.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", got, cred)
+	}
+
+	// Editing keeps unmanaged properties and clears dropped fields.
+	item["notes"] = "rotated quarterly"
+	updated := &Credential{Username: "oauth2", Password: "glpat-new"}
+	if err := b.Store("gitlab.com/org1", updated); err != nil {
+		t.Fatalf("Store() update error = %v", err)
+	}
+	if len(fake.items) != 1 {
+		t.Fatalf("items = %d, want 1", len(fake.items))
+	}
+	if fake.items["id-1"]["notes"] != "rotated quarterly" {
+		t.Errorf("notes = %v, want preserved", fake.items["id-1"]["notes"])
+	}
+	got, err = b.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() after update error = %v", err)
+	}
+	if *got != *updated {
+		t.Errorf("Get() after update = %+v, want %+v", got, updated)
+	}
+
+	if err := b.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := b.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+	if err := b.Erase("gitlab.com/org1"); err != nil {
+		t.Errorf("Erase() of missing item error = %v", err)
+	}
+
+	statusCalls := 0
+	for _, call := range fake.calls {
+		if call[0] == "status" {
+			statusCalls++
+		}
+	}
+	if statusCalls != 1 {
+		t.Errorf("bw status called %d times, want 1", statusCalls)
+	}
+}
+
+func TestBitwardenStoreExactNameMatch(t *testing.T) {
+	fake := newFakeBW()
+	b := NewBitwardenStore("", "", "")
+	b.run = fake.run
+
+	if err := b.Store("github.com/acme-corp", &Credential{Username: "u", Password: "p"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if _, err := b.Get("github.com/acme"); err != ErrNotFound {
+		t.Errorf("Get() of prefix namespace error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBitwardenStoreLocked(t *testing.T) {
+	tests := []struct {
+		status  string
+		session string
+		want    string
+	}{
+		{"locked", "", "export BW_SESSION=$(bw unlock --raw)"},
+		{"locked", "stale", "BW_SESSION is set but not valid"},
+		{"unauthenticated", "", "bw login"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.session, func(t *testing.T) {
+			t.Setenv("BW_SESSION", tt.session)
+			fake := newFakeBW()
+			fake.status = tt.status
+			b := NewBitwardenStore("", "", "")
+			b.run = fake.run
+
+			_, err := b.Get("gitlab.com/org1")
+			if err == nil || err == ErrNotFound || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Get() error = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
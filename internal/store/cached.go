@@ -0,0 +1,110 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// Cache holds credentials for a while, e.g. in the cache daemon. Get
+// reports found with a nil credential for a cached miss.
+type Cache interface {
+	Get(key string) (cred *Credential, found bool, err error)
+	Put(key string, cred *Credential, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// CachedStore consults a cache before its backend. Cache errors never fail
+// an operation: the backend stays the source of truth.
+type CachedStore struct {
+	backend CredentialStore
+	cache   Cache
+	prefix  string
+	ttl     func(namespace string) time.Duration
+}
+
+// NewCachedStore wraps backend with cache. Entries are keyed by prefix and
+// namespace, so backends sharing a cache don't see each other's entries;
+// ttl returns how long a namespace's entries are kept (zero disables
+// caching it).
+func NewCachedStore(backend CredentialStore, cache Cache, prefix string, ttl func(namespace string) time.Duration) *CachedStore {
+	return &CachedStore{backend: backend, cache: cache, prefix: prefix, ttl: ttl}
+}
+
+func (c *CachedStore) Name() string {
+	return c.backend.Name()
+}
+
+func (c *CachedStore) key(namespace string) string {
+	return c.prefix + ":" + namespace
+}
+
+// Get returns the cached credential, else the backend's. Misses are cached
+// too, since a lookup tries several namespace levels.
+func (c *CachedStore) Get(namespace string) (*Credential, error) {
+	ttl := c.ttl(namespace)
+	if ttl <= 0 {
+		return c.backend.Get(namespace)
+	}
+
+	if cred, found, err := c.cache.Get(c.key(namespace)); err == nil && found {
+		if cred == nil {
+			return nil, ErrNotFound
+		}
+		return cred, nil
+	}
+
+	cred, err := c.backend.Get(namespace)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.cache.Put(c.key(namespace), nil, ttl)
+	case err == nil:
+		c.put(namespace, cred, ttl)
+	}
+	return cred, err
+}
+
+func (c *CachedStore) Store(namespace string, cred *Credential) error {
+	return c.write(namespace, cred, c.backend.Store)
+}
+
+// Update passes through to the backend's Update, or Store if it has none.
+func (c *CachedStore) Update(namespace string, cred *Credential) error {
+	if u, ok := c.backend.(Updater); ok {
+		return c.write(namespace, cred, u.Update)
+	}
+	return c.write(namespace, cred, c.backend.Store)
+}
+
+func (c *CachedStore) write(namespace string, cred *Credential, fn func(string, *Credential) error) error {
+	if err := fn(namespace, cred); err != nil {
+		if !errors.Is(err, ErrReadOnly) {
+			// The backend may have been partly written.
+			c.cache.Delete(c.key(namespace))
+		}
+		return err
+	}
+	if ttl := c.ttl(namespace); ttl > 0 {
+		c.put(namespace, cred, ttl)
+	}
+	return nil
+}
+
+// Erase drops the namespace from the cache before the backend, so a
+// rejected credential isn't served again even if the backend fails.
+func (c *CachedStore) Erase(namespace string) error {
+	c.cache.Delete(c.key(namespace))
+	return c.backend.Erase(namespace)
+}
+
+// put caches cred for at most ttl and never past its expiry.
+func (c *CachedStore) put(namespace string, cred *Credential, ttl time.Duration) {
+	if cred.PasswordExpiryUTC != 0 {
+		left := time.Until(time.Unix(cred.PasswordExpiryUTC, 0))
+		if left <= 0 {
+			c.cache.Delete(c.key(namespace))
+			return
+		}
+		ttl = min(ttl, left)
+	}
+	c.cache.Put(c.key(namespace), cred, ttl)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+// mapCache is an in-memory Cache ignoring TTLs.
+type mapCache struct {
+	entries map[string]*Credential
+}
+
+func (m *mapCache) Get(key string) (*Credential, bool, error) {
+	cred, ok := m.entries[key]
+	return cred, ok, nil
+}
+
+func (m *mapCache) Put(key string, cred *Credential, ttl time.Duration) error {
+	m.entries[key] = cred
+	return nil
+}
+
+func (m *mapCache) Delete(key string) error {
+	delete(m.entries, key)
+	return nil
+}
+
+func TestCachedStore(t *testing.T) {
+	backend := newMemStore("keychain")
+	backend.creds["gitlab.com/org1"] = &Credential{Username: "oauth2", Password: "glpat-abc"}
+	cache := &mapCache{entries: make(map[string]*Credential)}
+	s := NewCachedStore(backend, cache, "keychain", func(string) time.Duration { return time.Minute })
+
+	if got, err := s.Get("gitlab.com/org1"); err != nil || got.Password != "glpat-abc" {
+		t.Fatalf("Get() = %+v, %v", got, err)
+	}
+	if _, err := s.Get("gitlab.com/org2"); err != ErrNotFound {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// Served from the cache without asking the backend.
+	delete(backend.creds, "gitlab.com/org1")
+	backend.creds["gitlab.com/org2"] = &Credential{Username: "oauth2", Password: "glpat-new"}
+	if got, err := s.Get("gitlab.com/org1"); err != nil || got.Password != "glpat-abc" {
+		t.Errorf("Get() = %+v, %v, want cached credential", got, err)
+	}
+	if _, err := s.Get("gitlab.com/org2"); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want cached miss", err)
+	}
+
+	if err := s.Store("gitlab.com/org2", &Credential{Username: "oauth2", Password: "glpat-stored"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if got := cache.entries["keychain:gitlab.com/org2"]; got == nil || got.Password != "glpat-stored" {
+		t.Errorf("Store() cached %+v, want stored credential", got)
+	}
+
+	if err := s.Erase("gitlab.com/org2"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, ok := cache.entries["keychain:gitlab.com/org2"]; ok {
+		t.Error("Erase() left the namespace cached")
+	}
+}
+
+func TestCachedStoreSkipsExpired(t *testing.T) {
+	backend := newMemStore("keychain")
+	backend.creds["gitlab.com/org1"] = &Credential{Password: "glpat-old", PasswordExpiryUTC: time.Now().Add(-time.Hour).Unix()}
+	cache := &mapCache{entries: make(map[string]*Credential)}
+	s := NewCachedStore(backend, cache, "keychain", func(string) time.Duration { return time.Minute })
+
+	if _, err := s.Get("gitlab.com/org1"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, ok := cache.entries["keychain:gitlab.com/org1"]; ok {
+		t.Error("Get() cached an expired credential")
+	}
+}
+
+func TestCachedStoreDisabledNamespace(t *testing.T) {
+	backend := newMemStore("keychain")
+	backend.creds["gitlab.com/org1"] = &Credential{Password: "glpat-abc"}
+	cache := &mapCache{entries: make(map[string]*Credential)}
+	s := NewCachedStore(backend, cache, "keychain", func(string) time.Duration { return 0 })
+
+	if _, err := s.Get("gitlab.com/org1"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("Get() cached %v with a zero TTL", cache.entries)
+	}
+}
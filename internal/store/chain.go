@@ -0,0 +1,188 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Write policies of a ChainStore.
+const (
+	WriteFirst   = "first"
+	WriteAll     = "all"
+	WritePrimary = "primary"
+)
+
+// ChainStore combines several backends, e.g. while moving from the
+// keychain to 1Password. Get tries each in order; Store writes according
+// to the write policy. Erase removes the namespace from the policy's
+// backends and from any other backend holding the same secret, so a
+// rejected token isn't found again further down the chain.
+type ChainStore struct {
+	stores   []CredentialStore
+	policy   string
+	primary  int
+	backfill bool
+}
+
+// NewChain returns a store over stores in lookup order. policy is
+// WriteFirst, WriteAll or WritePrimary (writing to stores[primary]). With
+// backfill, a credential found in a later store is copied into the
+// earlier ones.
+func NewChain(stores []CredentialStore, policy string, primary int, backfill bool) (*ChainStore, error) {
+	if len(stores) == 0 {
+		return nil, errors.New("backend chain is empty")
+	}
+
+	switch policy {
+	case "", WriteFirst:
+		policy = WriteFirst
+	case WriteAll:
+	case WritePrimary:
+		if primary < 0 || primary >= len(stores) {
+			return nil, errors.New("primary backend is not in the chain")
+		}
+	default:
+		return nil, fmt.Errorf("unknown write policy %q", policy)
+	}
+
+	return &ChainStore{stores: stores, policy: policy, primary: primary, backfill: backfill}, nil
+}
+
+func (c *ChainStore) Name() string {
+	names := make([]string, len(c.stores))
+	for i, s := range c.stores {
+		names[i] = s.Name()
+	}
+	return "chain(" + strings.Join(names, ",") + ")"
+}
+
+// Get returns the first credential found. An expired credential is only
+// returned if no later store has a live one. Errors of individual stores
+// are returned only if no store has the credential.
+func (c *ChainStore) Get(namespace string) (*Credential, error) {
+	cred, index, err := c.get(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.backfill {
+		for _, s := range c.stores[:index] {
+			// Best effort: a store that can't take the copy is still
+			// served by the later one.
+			s.Store(namespace, cred)
+		}
+	}
+	return cred, nil
+}
+
+func (c *ChainStore) get(namespace string) (*Credential, int, error) {
+	var expired *Credential
+	expiredIndex := -1
+	var errs []error
+	now := time.Now()
+
+	for i, s := range c.stores {
+		cred, err := s.Get(namespace)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if cred.Expired(now) {
+			if expired == nil {
+				expired, expiredIndex = cred, i
+			}
+			continue
+		}
+		return cred, i, nil
+	}
+
+	if expired != nil {
+		return expired, expiredIndex, nil
+	}
+	if len(errs) > 0 {
+		return nil, -1, errors.Join(errs...)
+	}
+	return nil, -1, ErrNotFound
+}
+
+func (c *ChainStore) Store(namespace string, cred *Credential) error {
+	return c.each(c.targets(), func(s CredentialStore) error {
+		return s.Store(namespace, cred)
+	})
+}
+
+// Update rewrites the credential in the stores holding the same secret,
+// whatever the write policy. It returns ErrNotFound if none does.
+func (c *ChainStore) Update(namespace string, cred *Credential) error {
+	var holders []CredentialStore
+	for _, s := range c.stores {
+		if held, err := s.Get(namespace); err == nil && held.Password == cred.Password {
+			holders = append(holders, s)
+		}
+	}
+	if len(holders) == 0 {
+		return ErrNotFound
+	}
+
+	return c.each(holders, func(s CredentialStore) error {
+		return s.Store(namespace, cred)
+	})
+}
+
+func (c *ChainStore) Erase(namespace string) error {
+	targets := slices.Clone(c.targets())
+
+	current, _, err := c.get(namespace)
+	if err == nil {
+		for _, s := range c.stores {
+			if slices.Contains(targets, s) {
+				continue
+			}
+			if held, err := s.Get(namespace); err == nil && held.Password == current.Password {
+				targets = append(targets, s)
+			}
+		}
+	}
+
+	return c.each(targets, func(s CredentialStore) error {
+		return s.Erase(namespace)
+	})
+}
+
+// targets returns the stores the write policy writes to.
+func (c *ChainStore) targets() []CredentialStore {
+	switch c.policy {
+	case WriteAll:
+		return c.stores
+	case WritePrimary:
+		return c.stores[c.primary : c.primary+1]
+	}
+	return c.stores[:1]
+}
+
+// each applies fn to stores, skipping read-only ones. It returns
+// ErrReadOnly only if every store is read-only.
+func (c *ChainStore) each(stores []CredentialStore, fn func(CredentialStore) error) error {
+	var errs []error
+	readOnly := 0
+	for _, s := range stores {
+		err := fn(s)
+		switch {
+		case errors.Is(err, ErrReadOnly):
+			readOnly++
+		case err != nil:
+			errs = append(errs, err)
+		}
+	}
+
+	if readOnly == len(stores) {
+		return ErrReadOnly
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory CredentialStore.
+type memStore struct {
+	name     string
+	creds    map[string]*Credential
+	readOnly bool
+}
+
+func newMemStore(name string) *memStore {
+	return &memStore{name: name, creds: make(map[string]*Credential)}
+}
+
+func (m *memStore) Name() string { return m.name }
+
+func (m *memStore) Get(namespace string) (*Credential, error) {
+	if c, ok := m.creds[namespace]; ok {
+		return c, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memStore) Store(namespace string, cred *Credential) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	m.creds[namespace] = cred
+	return nil
+}
+
+func (m *memStore) Erase(namespace string) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	delete(m.creds, namespace)
+	return nil
+}
+
+func TestChainStoreGet(t *testing.T) {
+	keychain, op := newMemStore("keychain"), newMemStore("onepassword")
+	op.creds["gitlab.com/org1"] = &Credential{Username: "oauth2", Password: "glpat-op"}
+
+	chain, err := NewChain([]CredentialStore{keychain, op}, WriteFirst, -1, false)
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+	got, err := chain.Get("gitlab.com/org1")
+	if err != nil || got.Password != "glpat-op" {
+		t.Fatalf("Get() = %+v, %v, want glpat-op from later store", got, err)
+	}
+	if _, ok := keychain.creds["gitlab.com/org1"]; ok {
+		t.Error("Get() back-filled without backfill")
+	}
+
+	chain.backfill = true
+	if _, err := chain.Get("gitlab.com/org1"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if keychain.creds["gitlab.com/org1"] != op.creds["gitlab.com/org1"] {
+		t.Error("Get() with backfill didn't copy into the earlier store")
+	}
+
+	// A live credential further down wins over an expired one.
+	keychain.creds["gitlab.com/org1"] = &Credential{Password: "old", PasswordExpiryUTC: time.Now().Add(-time.Hour).Unix()}
+	if got, _ := chain.Get("gitlab.com/org1"); got.Password != "glpat-op" {
+		t.Errorf("Get() = %q, want live credential over expired one", got.Password)
+	}
+
+	if _, err := chain.Get("github.com/acme"); err != ErrNotFound {
+		t.Errorf("Get() of missing namespace error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestChainStoreWritePolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		primary int
+		want    []bool // which stores receive the credential
+	}{
+		{WriteFirst, -1, []bool{true, false, false}},
+		{WriteAll, -1, []bool{true, true, true}},
+		{WritePrimary, 1, []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			stores := []*memStore{newMemStore("a"), newMemStore("b"), newMemStore("c")}
+			chain, err := NewChain([]CredentialStore{stores[0], stores[1], stores[2]}, tt.policy, tt.primary, false)
+			if err != nil {
+				t.Fatalf("NewChain() error = %v", err)
+			}
+
+			if err := chain.Store("gitlab.com/org1", &Credential{Password: "p"}); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+			for i, s := range stores {
+				if _, ok := s.creds["gitlab.com/org1"]; ok != tt.want[i] {
+					t.Errorf("store %s has credential = %v, want %v", s.name, ok, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChainStoreEraseStaleCopies(t *testing.T) {
+	op, keychain := newMemStore("onepassword"), newMemStore("keychain")
+	keychain.creds["gitlab.com/org1"] = &Credential{Password: "rejected"}
+	keychain.creds["gitlab.com/org2"] = &Credential{Password: "other"}
+	op.creds["gitlab.com/org2"] = &Credential{Password: "newer"}
+
+	chain, _ := NewChain([]CredentialStore{op, keychain}, WriteFirst, -1, false)
+
+	// The rejected token only lives in the second store: erasing it there
+	// keeps get from returning it again.
+	if err := chain.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, ok := keychain.creds["gitlab.com/org1"]; ok {
+		t.Error("Erase() left the rejected credential in the later store")
+	}
+
+	// A different secret in a store outside the policy is left alone.
+	if err := chain.Erase("gitlab.com/org2"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, ok := op.creds["gitlab.com/org2"]; ok {
+		t.Error("Erase() kept the credential in the first store")
+	}
+	if _, ok := keychain.creds["gitlab.com/org2"]; !ok {
+		t.Error("Erase() removed an unrelated credential from the later store")
+	}
+}
+
+func TestChainStoreUpdate(t *testing.T) {
+	op, keychain, file := newMemStore("onepassword"), newMemStore("keychain"), newMemStore("file")
+	keychain.creds["gitlab.com/org1"] = &Credential{Password: "legacy"}
+	file.creds["gitlab.com/org1"] = &Credential{Password: "other"}
+
+	chain, _ := NewChain([]CredentialStore{op, keychain, file}, WriteAll, -1, false)
+	if err := chain.Update("gitlab.com/org1", &Credential{Password: "legacy", LastUsedAt: 1700000000}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if keychain.creds["gitlab.com/org1"].LastUsedAt != 1700000000 {
+		t.Error("Update() didn't rewrite the store holding the credential")
+	}
+	if _, ok := op.creds["gitlab.com/org1"]; ok {
+		t.Error("Update() copied the credential into a store that didn't hold it")
+	}
+	if file.creds["gitlab.com/org1"].Password != "other" {
+		t.Error("Update() overwrote a different secret")
+	}
+
+	if err := chain.Update("gitlab.com/org2", &Credential{Password: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() of a missing credential error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestChainStoreReadOnly(t *testing.T) {
+	vault, keychain := newMemStore("vault"), newMemStore("keychain")
+	vault.readOnly = true
+
+	all, _ := NewChain([]CredentialStore{vault, keychain}, WriteAll, -1, false)
+	if err := all.Store("gitlab.com/org1", &Credential{Password: "p"}); err != nil {
+		t.Errorf("Store() with one read-only store error = %v", err)
+	}
+	if _, ok := keychain.creds["gitlab.com/org1"]; !ok {
+		t.Error("Store() skipped the writable store")
+	}
+
+	first, _ := NewChain([]CredentialStore{vault, keychain}, WriteFirst, -1, false)
+	if err := first.Store("gitlab.com/org1", &Credential{Password: "p"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Store() to read-only first store error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestNewChainInvalid(t *testing.T) {
+	a := newMemStore("a")
+	if _, err := NewChain(nil, WriteFirst, -1, false); err == nil {
+		t.Error("NewChain() with no stores should fail")
+	}
+	if _, err := NewChain([]CredentialStore{a}, "some", -1, false); err == nil {
+		t.Error("NewChain() with unknown policy should fail")
+	}
+	if _, err := NewChain([]CredentialStore{a}, WritePrimary, 3, false); err == nil {
+		t.Error("NewChain() with primary out of range should fail")
+	}
+}
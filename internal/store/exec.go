@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// execProtocolVersion is sent with every request so plugins can reject
+// versions they don't speak.
+const execProtocolVersion = 1
+
+// Error codes a plugin can answer with.
+const (
+	execErrNotFound = "not_found"
+	execErrReadOnly = "read_only"
+)
+
+// ExecStore delegates to an external plugin, so secrets managers can be
+// supported without changes here. Each operation runs the command once,
+// writes one JSON request to its stdin and reads one JSON response from
+// its stdout:
+//
+//	{"version": 1, "operation": "get", "namespace": "gitlab.com/org1"}
+//	{"credential": {"username": "oauth2", "password": "glpat-abc"}}
+//
+// Operations are get, store (with "credential"), erase and list (answered
+// with "namespaces"). Failures are reported as
+// {"error": {"code": "not_found", "message": "..."}}, where code is
+// not_found, read_only or anything else for other errors.
+type ExecStore struct {
+	command string
+	args    []string
+}
+
+func NewExecStore(command string, args []string) *ExecStore {
+	return &ExecStore{command: command, args: args}
+}
+
+func (e *ExecStore) Name() string {
+	return "exec"
+}
+
+type execRequest struct {
+	Version    int         `json:"version"`
+	Operation  string      `json:"operation"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Credential *Credential `json:"credential,omitempty"`
+}
+
+type execResponse struct {
+	Credential *Credential `json:"credential,omitempty"`
+	Namespaces []string    `json:"namespaces,omitempty"`
+	Error      *execError  `json:"error,omitempty"`
+}
+
+type execError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ExecStore) Get(namespace string) (*Credential, error) {
+	resp, err := e.call(execRequest{Operation: "get", Namespace: namespace})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("exec get %q: %w", namespace, err)
+	}
+	if resp.Credential == nil {
+		return nil, ErrNotFound
+	}
+	return resp.Credential, nil
+}
+
+func (e *ExecStore) Store(namespace string, cred *Credential) error {
+	if _, err := e.call(execRequest{Operation: "store", Namespace: namespace, Credential: cred}); err != nil {
+		return fmt.Errorf("exec store %q: %w", namespace, err)
+	}
+	return nil
+}
+
+func (e *ExecStore) Erase(namespace string) error {
+	if _, err := e.call(execRequest{Operation: "erase", Namespace: namespace}); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("exec erase %q: %w", namespace, err)
+	}
+	return nil
+}
+
+// List returns the namespaces the plugin holds credentials for.
+func (e *ExecStore) List() ([]string, error) {
+	resp, err := e.call(execRequest{Operation: "list"})
+	if err != nil {
+		return nil, fmt.Errorf("exec list: %w", err)
+	}
+	return resp.Namespaces, nil
+}
+
+// call runs the plugin for one request. Error responses are returned as
+// ErrNotFound, ErrReadOnly or an error carrying the plugin's message.
+func (e *ExecStore) call(req execRequest) (*execResponse, error) {
+	req.Version = execProtocolVersion
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(e.command, e.args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+
+	// A plugin may exit non-zero along with an error response; prefer the
+	// response, which says what went wrong.
+	var resp execResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("%s: %w: %s", e.command, runErr, strings.TrimSpace(stderr.String()))
+		}
+		if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+			return &resp, nil
+		}
+		return nil, fmt.Errorf("%s: invalid response: %w", e.command, err)
+	}
+
+	if resp.Error != nil {
+		switch resp.Error.Code {
+		case execErrNotFound:
+			return nil, ErrNotFound
+		case execErrReadOnly:
+			return nil, ErrReadOnly
+		}
+		return nil, fmt.Errorf("%s: %s", e.command, resp.Error.Message)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("%s: %w: %s", e.command, runErr, strings.TrimSpace(stderr.String()))
+	}
+	return &resp, nil
+}
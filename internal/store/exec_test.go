@@ -0,0 +1,149 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// referencePluginEnv makes the test binary act as the reference exec
+// plugin, keeping credentials as JSON files in the directory it names.
+const referencePluginEnv = "GIT_CREDENTIALS_ORG_REFERENCE_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(referencePluginEnv); dir != "" {
+		os.Exit(referencePlugin(dir, os.Stdin, os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+// referencePlugin is a complete exec plugin: it answers one request and
+// returns the exit code.
+func referencePlugin(dir string, stdin io.Reader, stdout io.Writer) int {
+	var req struct {
+		Version    int             `json:"version"`
+		Operation  string          `json:"operation"`
+		Namespace  string          `json:"namespace"`
+		Credential json.RawMessage `json:"credential"`
+	}
+	reply := func(resp any) int {
+		json.NewEncoder(stdout).Encode(resp)
+		return 0
+	}
+	fail := func(code, message string) int {
+		json.NewEncoder(stdout).Encode(map[string]any{"error": map[string]string{"code": code, "message": message}})
+		return 1
+	}
+
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return fail("invalid_request", err.Error())
+	}
+	if req.Version != 1 {
+		return fail("unsupported_version", "only version 1 is supported")
+	}
+	if os.Getenv(referencePluginEnv+"_READONLY") != "" && (req.Operation == "store" || req.Operation == "erase") {
+		return fail("read_only", "plugin is read-only")
+	}
+
+	path := filepath.Join(dir, url.PathEscape(req.Namespace)+".json")
+	switch req.Operation {
+	case "get":
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return fail("not_found", req.Namespace+" not found")
+		} else if err != nil {
+			return fail("internal", err.Error())
+		}
+		return reply(map[string]json.RawMessage{"credential": data})
+	case "store":
+		if err := os.WriteFile(path, req.Credential, 0600); err != nil {
+			return fail("internal", err.Error())
+		}
+		return reply(map[string]any{})
+	case "erase":
+		if err := os.Remove(path); os.IsNotExist(err) {
+			return fail("not_found", req.Namespace+" not found")
+		} else if err != nil {
+			return fail("internal", err.Error())
+		}
+		return reply(map[string]any{})
+	case "list":
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		namespaces := []string{}
+		for _, f := range files {
+			ns, _ := url.PathUnescape(strings.TrimSuffix(filepath.Base(f), ".json"))
+			namespaces = append(namespaces, ns)
+		}
+		return reply(map[string]any{"namespaces": namespaces})
+	}
+	return fail("unsupported_operation", "unknown operation "+req.Operation)
+}
+
+func newReferencePluginStore(t *testing.T) *ExecStore {
+	t.Helper()
+	t.Setenv(referencePluginEnv, t.TempDir())
+	return NewExecStore(os.Args[0], nil)
+}
+
+func TestExecStoreRoundTrip(t *testing.T) {
+	e := newReferencePluginStore(t)
+
+	if _, err := e.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000, AuthType: "Bearer"}
+	if err := e.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := e.Store("github.com/acme", &Credential{Username: "x-access-token", Password: "ghp_x"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got, err := e.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", *got, *cred)
+	}
+
+	namespaces, err := e.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	sort.Strings(namespaces)
+	if want := []string{"github.com/acme", "gitlab.com/org1"}; strings.Join(namespaces, ",") != strings.Join(want, ",") {
+		t.Errorf("List() = %v, want %v", namespaces, want)
+	}
+
+	if err := e.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := e.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+	if err := e.Erase("gitlab.com/org1"); err != nil {
+		t.Errorf("Erase() of missing namespace error = %v", err)
+	}
+}
+
+func TestExecStoreErrors(t *testing.T) {
+	e := newReferencePluginStore(t)
+	t.Setenv(referencePluginEnv+"_READONLY", "1")
+
+	if err := e.Store("gitlab.com/org1", &Credential{Password: "x"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Store() on read-only plugin error = %v, want ErrReadOnly", err)
+	}
+
+	missing := NewExecStore(filepath.Join(t.TempDir(), "no-such-plugin"), nil)
+	if _, err := missing.Get("gitlab.com/org1"); err == nil || err == ErrNotFound {
+		t.Errorf("Get() with missing plugin error = %v, want exec failure", err)
+	}
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// filePassphraseEnv holds the passphrase for the file backend when no
+// identity file is configured.
+const filePassphraseEnv = "GIT_CREDENTIALS_ORG_PASSPHRASE"
+
+// fileFormatVersion is bumped on incompatible changes to fileContents.
+const fileFormatVersion = 1
+
+// FileStore keeps every namespace in a single age-encrypted file, for
+// machines without a keyring daemon (headless Linux, CI runners). Writes
+// are atomic (temp file + rename) and serialized across concurrent git
+// processes with a lock file next to the store.
+type FileStore struct {
+	path string
+
+	// identityFile is an age X25519 identity file; if empty, a passphrase
+	// from passphraseFile or $GIT_CREDENTIALS_ORG_PASSPHRASE is used.
+	identityFile   string
+	passphraseFile string
+
+	// scryptWorkFactor overrides age's default for passphrase encryption;
+	// lowered in tests.
+	scryptWorkFactor int
+}
+
+type fileContents struct {
+	Version     int                    `json:"version"`
+	Credentials map[string]*Credential `json:"credentials"`
+}
+
+func NewFileStore(path, identityFile, passphraseFile string) *FileStore {
+	return &FileStore{path: path, identityFile: identityFile, passphraseFile: passphraseFile}
+}
+
+func (f *FileStore) Name() string {
+	return "file"
+}
+
+func (f *FileStore) Get(namespace string) (*Credential, error) {
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	unlock, err := lockFile(f.path+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("file get %q: %w", namespace, err)
+	}
+	defer unlock()
+
+	contents, err := f.read()
+	if err != nil {
+		return nil, fmt.Errorf("file get %q: %w", namespace, err)
+	}
+
+	cred, ok := contents.Credentials[namespace]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cred, nil
+}
+
+func (f *FileStore) Store(namespace string, cred *Credential) error {
+	return f.update(func(c *fileContents) {
+		c.Credentials[namespace] = cred
+	})
+}
+
+func (f *FileStore) Erase(namespace string) error {
+	return f.update(func(c *fileContents) {
+		delete(c.Credentials, namespace)
+	})
+}
+
+// List returns the namespaces in the store, sorted.
+func (f *FileStore) List() ([]string, error) {
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	unlock, err := lockFile(f.path+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("file list: %w", err)
+	}
+	defer unlock()
+
+	contents, err := f.read()
+	if err != nil {
+		return nil, fmt.Errorf("file list: %w", err)
+	}
+	return slices.Sorted(maps.Keys(contents.Credentials)), nil
+}
+
+// update applies fn to the decrypted contents under an exclusive lock and
+// writes the result back atomically.
+func (f *FileStore) update(fn func(*fileContents)) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("file store: creating directory: %w", err)
+	}
+
+	unlock, err := lockFile(f.path+".lock", true)
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	defer unlock()
+
+	contents, err := f.read()
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+
+	fn(contents)
+
+	if err := f.write(contents); err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	return nil
+}
+
+// read decrypts the store, returning empty contents if it doesn't exist yet.
+func (f *FileStore) read() (*fileContents, error) {
+	contents := &fileContents{Version: fileFormatVersion, Credentials: make(map[string]*Credential)}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return contents, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := checkPrivate(file); err != nil {
+		return nil, err
+	}
+
+	identities, err := f.identities()
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := age.Decrypt(file, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", f.path, err)
+	}
+
+	if err := json.NewDecoder(plain).Decode(contents); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", f.path, err)
+	}
+	if contents.Version != fileFormatVersion {
+		return nil, fmt.Errorf("%s: unsupported format version %d", f.path, contents.Version)
+	}
+	if contents.Credentials == nil {
+		contents.Credentials = make(map[string]*Credential)
+	}
+
+	return contents, nil
+}
+
+// write encrypts contents over the store atomically.
+func (f *FileStore) write(contents *fileContents) error {
+	recipients, err := f.recipients()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	return writeFileAtomic(f.path, func(file io.Writer) error {
+		w, err := age.Encrypt(file, recipients...)
+		if err != nil {
+			return fmt.Errorf("encrypting: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("encrypting: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("encrypting: %w", err)
+		}
+		return nil
+	})
+}
+
+// writeFileAtomic writes a 0600 temporary file in the same directory as
+// path with fn and renames it over path, so readers never see a partial
+// file.
+func writeFileAtomic(path string, fn func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := tmp.Chmod(0600); err != nil {
+		return err
+	}
+
+	if err := fn(tmp); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileStore) identities() ([]age.Identity, error) {
+	if f.identityFile != "" {
+		data, err := readPrivateFile(f.identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading identity: %w", err)
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("parsing identity %s: %w", f.identityFile, err)
+		}
+		return ids, nil
+	}
+
+	passphrase, err := f.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	id, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{id}, nil
+}
+
+func (f *FileStore) recipients() ([]age.Recipient, error) {
+	if f.identityFile != "" {
+		ids, err := f.identities()
+		if err != nil {
+			return nil, err
+		}
+		var recipients []age.Recipient
+		for _, id := range ids {
+			x, ok := id.(*age.X25519Identity)
+			if !ok {
+				return nil, fmt.Errorf("identity %s: only X25519 identities are supported", f.identityFile)
+			}
+			recipients = append(recipients, x.Recipient())
+		}
+		return recipients, nil
+	}
+
+	passphrase, err := f.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if f.scryptWorkFactor > 0 {
+		r.SetWorkFactor(f.scryptWorkFactor)
+	}
+	return []age.Recipient{r}, nil
+}
+
+func (f *FileStore) passphrase() (string, error) {
+	if f.passphraseFile != "" {
+		data, err := readPrivateFile(f.passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("reading passphrase: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if p := os.Getenv(filePassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	return "", errors.New("no identity or passphrase configured (set identity or passphrase_file in [backends.file], or " + filePassphraseEnv + ")")
+}
+
+// checkPrivate refuses files readable or writable by group or others,
+// like ssh does for private keys.
+func checkPrivate(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s has permissions %04o, want 0600", file.Name(), perm)
+	}
+	return nil
+}
+
+func readPrivateFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := checkPrivate(file); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
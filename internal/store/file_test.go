@@ -0,0 +1,161 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func writeIdentity(t *testing.T, dir string) string {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+	path := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(path, []byte(id.String()+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestFileStoreIdentityRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "credentials.age")
+	f := NewFileStore(path, writeIdentity(t, dir), "")
+
+	if _, err := f.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before any store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000}
+	if err := f.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := f.Store("github.com/acme", &Credential{Username: "x-access-token", Password: "ghp_x"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got, err := f.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", *got, *cred)
+	}
+
+	if got, err := f.List(); err != nil || !slices.Equal(got, []string{"github.com/acme", "gitlab.com/org1"}) {
+		t.Errorf("List() = %v, %v", got, err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if strings.Contains(string(raw), "glpat-abc") {
+		t.Error("store file contains the plaintext secret")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("store file permissions = %04o, want 0600", perm)
+	}
+
+	if err := f.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := f.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+	if _, err := f.Get("github.com/acme"); err != nil {
+		t.Errorf("Erase() removed another namespace: %v", err)
+	}
+}
+
+func TestFileStorePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.age")
+
+	t.Setenv(filePassphraseEnv, "correct horse")
+	f := NewFileStore(path, "", "")
+	f.scryptWorkFactor = 10
+
+	if err := f.Store("gitlab.com/org1", &Credential{Username: "oauth2", Password: "tok"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if got, err := f.Get("gitlab.com/org1"); err != nil || got.Password != "tok" {
+		t.Fatalf("Get() = %+v, %v", got, err)
+	}
+
+	t.Setenv(filePassphraseEnv, "wrong")
+	if _, err := f.Get("gitlab.com/org1"); err == nil {
+		t.Error("Get() with the wrong passphrase should fail")
+	}
+
+	t.Setenv(filePassphraseEnv, "")
+	if _, err := f.Get("gitlab.com/org1"); err == nil {
+		t.Error("Get() without a passphrase should fail")
+	}
+}
+
+func TestFileStoreRejectsLoosePermissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.age")
+	f := NewFileStore(path, writeIdentity(t, dir), "")
+
+	if err := f.Store("gitlab.com/org1", &Credential{Username: "oauth2", Password: "tok"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	if _, err := f.Get("gitlab.com/org1"); err == nil || !strings.Contains(err.Error(), "permissions") {
+		t.Errorf("Get() error = %v, want permissions error", err)
+	}
+}
+
+func TestFileStoreConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.age")
+	identity := writeIdentity(t, dir)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Separate stores mimic separate git processes.
+			f := NewFileStore(path, identity, "")
+			ns := fmt.Sprintf("gitlab.com/org%d", i)
+			errs <- f.Store(ns, &Credential{Username: "oauth2", Password: ns})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	f := NewFileStore(path, identity, "")
+	for i := 0; i < writers; i++ {
+		ns := fmt.Sprintf("gitlab.com/org%d", i)
+		if got, err := f.Get(ns); err != nil || got.Password != ns {
+			t.Errorf("Get(%s) = %+v, %v; lost a concurrent write", ns, got, err)
+		}
+	}
+}
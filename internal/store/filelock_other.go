@@ -0,0 +1,9 @@
+//go:build !unix
+
+package store
+
+// lockFile is a no-op where flock isn't available; concurrent writers may
+// race, but each write is still atomic.
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,876 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/imcitius/git-credentials-org/internal/argon2d"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/twofish"
+)
+
+// This file implements the parts of the KeePass KDBX 4 format the keepass
+// backend needs: reading and writing a database while keeping everything
+// it doesn't understand (other entries, metadata, attachments) intact.
+
+const (
+	kdbxSignature1   = 0x9AA2D903
+	kdbxSignature2   = 0xB54BFB67
+	kdbxMajorVersion = 4
+)
+
+// Outer header field IDs.
+const (
+	kdbxHeaderEnd         = 0
+	kdbxHeaderCipherID    = 2
+	kdbxHeaderCompression = 3
+	kdbxHeaderMasterSeed  = 4
+	kdbxHeaderIV          = 7
+	kdbxHeaderKDF         = 11
+)
+
+// Inner header field IDs.
+const (
+	kdbxInnerEnd       = 0
+	kdbxInnerStreamID  = 1
+	kdbxInnerStreamKey = 2
+	kdbxInnerBinary    = 3
+
+	kdbxInnerStreamChaCha20 = 3
+)
+
+var (
+	kdbxCipherAES256   = mustHex("31c1f2e6bf714350be5805216afc5aff")
+	kdbxCipherChaCha20 = mustHex("d6038a2b8b6f4cb5a524339a31dbb59a")
+	kdbxCipherTwofish  = mustHex("ad68f29f576f4bb9a36ad47af965346c")
+
+	kdbxKDFAES      = mustHex("c9d9f39a628a4460bf740d08c18a4fea")
+	kdbxKDFArgon2d  = mustHex("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdbxKDFArgon2id = mustHex("9e298b1956db4773b23dfc3ec6f0a1e6")
+)
+
+// errKDBXCredentials is returned when the header HMAC doesn't verify,
+// which almost always means a wrong password or key file.
+var errKDBXCredentials = errors.New("wrong password or key file, or the database is corrupt")
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type kdbxField struct {
+	id   byte
+	data []byte
+}
+
+// kdbxDatabase is a decrypted KDBX 4 database.
+type kdbxDatabase struct {
+	minorVersion uint16
+	header       []kdbxField
+
+	// transformedKey is the KDF output, kept so saving doesn't pay for the
+	// KDF again; the KDF parameters in the header are saved unchanged.
+	transformedKey []byte
+
+	// binaries are the attachments from the inner header, kept verbatim.
+	binaries [][]byte
+
+	// doc is the XML document with protected values decrypted.
+	doc *xmlNode
+}
+
+func (db *kdbxDatabase) field(id byte) []byte {
+	for _, f := range db.header {
+		if f.id == id {
+			return f.data
+		}
+	}
+	return nil
+}
+
+func (db *kdbxDatabase) setField(id byte, data []byte) {
+	for i := range db.header {
+		if db.header[i].id == id {
+			db.header[i].data = data
+			return
+		}
+	}
+	db.header = append(db.header, kdbxField{id, data})
+}
+
+// readKDBX decrypts a KDBX 4 database with the composite key.
+func readKDBX(r io.Reader, compositeKey []byte) (*kdbxDatabase, error) {
+	var hdr bytes.Buffer
+	tr := io.TeeReader(r, &hdr)
+
+	var sig [3]uint32
+	if err := binary.Read(tr, binary.LittleEndian, &sig); err != nil {
+		return nil, fmt.Errorf("reading signature: %w", err)
+	}
+	if sig[0] != kdbxSignature1 || sig[1] != kdbxSignature2 {
+		return nil, errors.New("not a KeePass database")
+	}
+	db := &kdbxDatabase{minorVersion: uint16(sig[2])}
+	if major := sig[2] >> 16; major != kdbxMajorVersion {
+		return nil, fmt.Errorf("unsupported KDBX version %d.%d (only KDBX 4 is supported)", major, db.minorVersion)
+	}
+
+	for {
+		f, err := readKDBXField(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		if f.id == kdbxHeaderEnd {
+			break
+		}
+		db.header = append(db.header, f)
+	}
+
+	var sums [64]byte
+	if _, err := io.ReadFull(r, sums[:]); err != nil {
+		return nil, fmt.Errorf("reading header hashes: %w", err)
+	}
+	if sha := sha256.Sum256(hdr.Bytes()); !hmac.Equal(sha[:], sums[:32]) {
+		return nil, errors.New("header checksum mismatch: database is corrupt")
+	}
+
+	kdf, err := parseVariantDictionary(db.field(kdbxHeaderKDF))
+	if err != nil {
+		return nil, fmt.Errorf("parsing KDF parameters: %w", err)
+	}
+	if db.transformedKey, err = kdbxTransformKey(compositeKey, kdf); err != nil {
+		return nil, err
+	}
+
+	hmacKey := db.hmacKey()
+	if !hmac.Equal(kdbxHeaderHMAC(hmacKey, hdr.Bytes()), sums[32:]) {
+		return nil, errKDBXCredentials
+	}
+
+	ciphertext, err := readKDBXBlocks(r, hmacKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := db.crypt(ciphertext, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload io.Reader = bytes.NewReader(plaintext)
+	if db.compressed() {
+		if payload, err = gzip.NewReader(payload); err != nil {
+			return nil, fmt.Errorf("decompressing: %w", err)
+		}
+	}
+
+	stream, err := db.readInnerHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	db.doc = &xmlNode{}
+	if err := xml.NewDecoder(payload).Decode(db.doc); err != nil {
+		return nil, fmt.Errorf("parsing XML: %w", err)
+	}
+	db.doc.trimSpace()
+	if err := db.doc.protect(stream, false); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// write encrypts the database with a fresh master seed, IV and inner
+// stream key.
+func (db *kdbxDatabase) write(w io.Writer) error {
+	seed := make([]byte, 32)
+	iv := make([]byte, 16)
+	if bytes.Equal(db.field(kdbxHeaderCipherID), kdbxCipherChaCha20) {
+		iv = iv[:12]
+	}
+	streamKey := make([]byte, 64)
+	for _, b := range [][]byte{seed, iv, streamKey} {
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+	}
+	db.setField(kdbxHeaderMasterSeed, seed)
+	db.setField(kdbxHeaderIV, iv)
+
+	var hdr bytes.Buffer
+	binary.Write(&hdr, binary.LittleEndian, [3]uint32{kdbxSignature1, kdbxSignature2, kdbxMajorVersion<<16 | uint32(db.minorVersion)})
+	for _, f := range db.header {
+		writeKDBXField(&hdr, f)
+	}
+	writeKDBXField(&hdr, kdbxField{kdbxHeaderEnd, []byte("\r\n\r\n")})
+
+	hmacKey := db.hmacKey()
+	sha := sha256.Sum256(hdr.Bytes())
+
+	var payload bytes.Buffer
+	var pw io.Writer = &payload
+	var gz *gzip.Writer
+	if db.compressed() {
+		gz = gzip.NewWriter(&payload)
+		pw = gz
+	}
+
+	var inner bytes.Buffer
+	binary.Write(&inner, binary.LittleEndian, uint32(kdbxInnerStreamChaCha20))
+	writeKDBXField(pw, kdbxField{kdbxInnerStreamID, inner.Bytes()})
+	writeKDBXField(pw, kdbxField{kdbxInnerStreamKey, streamKey})
+	for _, b := range db.binaries {
+		writeKDBXField(pw, kdbxField{kdbxInnerBinary, b})
+	}
+	writeKDBXField(pw, kdbxField{kdbxInnerEnd, nil})
+
+	stream, err := kdbxInnerStream(kdbxInnerStreamChaCha20, streamKey)
+	if err != nil {
+		return err
+	}
+	doc := db.doc.clone()
+	if err := doc.protect(stream, true); err != nil {
+		return err
+	}
+	io.WriteString(pw, xml.Header)
+	if err := xml.NewEncoder(pw).Encode(doc); err != nil {
+		return fmt.Errorf("encoding XML: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+
+	ciphertext, err := db.crypt(payload.Bytes(), true)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range [][]byte{hdr.Bytes(), sha[:], kdbxHeaderHMAC(hmacKey, hdr.Bytes())} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return writeKDBXBlocks(w, hmacKey, ciphertext)
+}
+
+func (db *kdbxDatabase) compressed() bool {
+	c := db.field(kdbxHeaderCompression)
+	return len(c) == 4 && binary.LittleEndian.Uint32(c) == 1
+}
+
+func (db *kdbxDatabase) hmacKey() []byte {
+	h := sha512.New()
+	h.Write(db.field(kdbxHeaderMasterSeed))
+	h.Write(db.transformedKey)
+	h.Write([]byte{1})
+	return h.Sum(nil)
+}
+
+// crypt encrypts or decrypts the payload with the header's cipher.
+func (db *kdbxDatabase) crypt(data []byte, encrypt bool) ([]byte, error) {
+	h := sha256.New()
+	h.Write(db.field(kdbxHeaderMasterSeed))
+	h.Write(db.transformedKey)
+	key := h.Sum(nil)
+	iv := db.field(kdbxHeaderIV)
+
+	id := db.field(kdbxHeaderCipherID)
+	if bytes.Equal(id, kdbxCipherChaCha20) {
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out, nil
+	}
+
+	var block cipher.Block
+	var err error
+	switch {
+	case bytes.Equal(id, kdbxCipherAES256):
+		block, err = aes.NewCipher(key)
+	case bytes.Equal(id, kdbxCipherTwofish):
+		block, err = twofish.NewCipher(key)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %x", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid encryption IV")
+	}
+
+	if encrypt {
+		pad := block.BlockSize() - len(data)%block.BlockSize()
+		data = append(bytes.Clone(data), bytes.Repeat([]byte{byte(pad)}, pad)...)
+		out := make([]byte, len(data))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		return out, nil
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errKDBXCredentials
+	}
+	return out[:len(out)-pad], nil
+}
+
+func (db *kdbxDatabase) readInnerHeader(r io.Reader) (cipher.Stream, error) {
+	var streamID uint32
+	var streamKey []byte
+	for {
+		f, err := readKDBXField(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading inner header: %w", err)
+		}
+		switch f.id {
+		case kdbxInnerEnd:
+			return kdbxInnerStream(streamID, streamKey)
+		case kdbxInnerStreamID:
+			if len(f.data) != 4 {
+				return nil, errors.New("invalid inner stream ID")
+			}
+			streamID = binary.LittleEndian.Uint32(f.data)
+		case kdbxInnerStreamKey:
+			streamKey = f.data
+		case kdbxInnerBinary:
+			db.binaries = append(db.binaries, f.data)
+		}
+	}
+}
+
+// kdbxInnerStream returns the cipher protecting values marked
+// Protected="True" in the XML.
+func kdbxInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	if id != kdbxInnerStreamChaCha20 {
+		return nil, fmt.Errorf("unsupported inner stream cipher %d", id)
+	}
+	h := sha512.Sum512(key)
+	return chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+}
+
+// kdbxTransformKey runs the KDF described by the header's parameters.
+func kdbxTransformKey(compositeKey []byte, params map[string]any) ([]byte, error) {
+	uuid, _ := params["$UUID"].([]byte)
+	salt, _ := params["S"].([]byte)
+
+	switch {
+	case bytes.Equal(uuid, kdbxKDFArgon2d), bytes.Equal(uuid, kdbxKDFArgon2id):
+		iterations, _ := params["I"].(uint64)
+		memory, _ := params["M"].(uint64)
+		parallelism, _ := params["P"].(uint32)
+		secret, _ := params["K"].([]byte)
+		data, _ := params["A"].([]byte)
+		if version, _ := params["V"].(uint32); version != 0x13 {
+			return nil, fmt.Errorf("unsupported Argon2 version %#x", version)
+		}
+		if iterations == 0 || iterations > math.MaxUint32 || memory/1024 > math.MaxUint32 || parallelism == 0 || parallelism > math.MaxUint8 {
+			return nil, errors.New("invalid Argon2 parameters")
+		}
+		// KeePassXC creates Argon2d databases by default.
+		if bytes.Equal(uuid, kdbxKDFArgon2d) {
+			return argon2d.Key(compositeKey, salt, secret, data, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+		}
+		if len(secret) > 0 || len(data) > 0 {
+			return nil, errors.New("argon2id secret keys are not supported")
+		}
+		return argon2.IDKey(compositeKey, salt, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+
+	case bytes.Equal(uuid, kdbxKDFAES):
+		rounds, _ := params["R"].(uint64)
+		block, err := aes.NewCipher(salt)
+		if err != nil {
+			return nil, fmt.Errorf("AES-KDF: %w", err)
+		}
+		key := bytes.Clone(compositeKey)
+		for i := uint64(0); i < rounds; i++ {
+			block.Encrypt(key[:16], key[:16])
+			block.Encrypt(key[16:], key[16:])
+		}
+		sum := sha256.Sum256(key)
+		return sum[:], nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %x", uuid)
+	}
+}
+
+// kdbxCompositeKey combines a password (if hasPassword) and the contents
+// of a key file (if any) into the key the KDF is run on.
+func kdbxCompositeKey(password string, hasPassword bool, keyFile []byte) ([]byte, error) {
+	h := sha256.New()
+	if hasPassword {
+		sum := sha256.Sum256([]byte(password))
+		h.Write(sum[:])
+	}
+	if keyFile != nil {
+		key, err := kdbxKeyFileKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(key)
+	}
+	return h.Sum(nil), nil
+}
+
+// kdbxKeyFileKey returns the key in a KeePass key file: XML (version 1.0
+// or 2.0), 32 raw bytes, 64 hex digits, or the hash of any other file.
+func kdbxKeyFileKey(data []byte) ([]byte, error) {
+	var kf struct {
+		XMLName xml.Name `xml:"KeyFile"`
+		Meta    struct {
+			Version string `xml:"Version"`
+		} `xml:"Meta"`
+		Key struct {
+			Data struct {
+				Hash  string `xml:"Hash,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"Data"`
+		} `xml:"Key"`
+	}
+	if xml.Unmarshal(data, &kf) == nil {
+		value := strings.Join(strings.Fields(kf.Key.Data.Value), "")
+		switch {
+		case strings.HasPrefix(kf.Meta.Version, "1."):
+			return base64.StdEncoding.DecodeString(value)
+		case strings.HasPrefix(kf.Meta.Version, "2."):
+			key, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing key file: %w", err)
+			}
+			sum := sha256.Sum256(key)
+			if kf.Key.Data.Hash != "" && !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Key.Data.Hash) {
+				return nil, errors.New("key file checksum mismatch")
+			}
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported key file version %q", kf.Meta.Version)
+		}
+	}
+
+	if len(data) == 32 {
+		return data, nil
+	}
+	if len(data) == 64 {
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func kdbxHeaderHMAC(hmacKey, header []byte) []byte {
+	return kdbxBlockHMAC(hmacKey, math.MaxUint64, header, false)
+}
+
+// kdbxBlockHMAC authenticates a block of the HMAC block stream; the
+// header is authenticated as block index 2^64-1 over its bytes alone.
+func kdbxBlockHMAC(hmacKey []byte, index uint64, data []byte, withIndex bool) []byte {
+	var idx [8]byte
+	binary.LittleEndian.PutUint64(idx[:], index)
+	key := sha512.Sum512(append(idx[:], hmacKey...))
+
+	mac := hmac.New(sha256.New, key[:])
+	if withIndex {
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+		mac.Write(idx[:])
+		mac.Write(size[:])
+	}
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func readKDBXBlocks(r io.Reader, hmacKey []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for index := uint64(0); ; index++ {
+		var head struct {
+			MAC  [32]byte
+			Size int32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &head); err != nil {
+			return nil, fmt.Errorf("reading block %d: %w", index, err)
+		}
+		if head.Size < 0 {
+			return nil, fmt.Errorf("block %d: invalid size", index)
+		}
+		data := make([]byte, head.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("reading block %d: %w", index, err)
+		}
+		if !hmac.Equal(kdbxBlockHMAC(hmacKey, index, data, true), head.MAC[:]) {
+			return nil, fmt.Errorf("block %d: HMAC mismatch: database is corrupt", index)
+		}
+		if head.Size == 0 {
+			return out.Bytes(), nil
+		}
+		out.Write(data)
+	}
+}
+
+const kdbxBlockSize = 1 << 20
+
+func writeKDBXBlocks(w io.Writer, hmacKey, data []byte) error {
+	for index := uint64(0); ; index++ {
+		n := min(len(data), kdbxBlockSize)
+		block := data[:n]
+		data = data[n:]
+
+		if _, err := w.Write(kdbxBlockHMAC(hmacKey, index, block, true)); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, int32(n)); err != nil {
+			return err
+		}
+		if _, err := w.Write(block); err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func readKDBXField(r io.Reader) (kdbxField, error) {
+	var head struct {
+		ID   byte
+		Size int32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &head); err != nil {
+		return kdbxField{}, err
+	}
+	if head.Size < 0 {
+		return kdbxField{}, errors.New("invalid field size")
+	}
+	data := make([]byte, head.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return kdbxField{}, err
+	}
+	return kdbxField{head.ID, data}, nil
+}
+
+func writeKDBXField(w io.Writer, f kdbxField) {
+	w.Write([]byte{f.id})
+	binary.Write(w, binary.LittleEndian, int32(len(f.data)))
+	w.Write(f.data)
+}
+
+// VariantDictionary value types.
+const (
+	vdUint32 = 0x04
+	vdUint64 = 0x05
+	vdBool   = 0x08
+	vdInt32  = 0x0C
+	vdInt64  = 0x0D
+	vdString = 0x18
+	vdBytes  = 0x42
+)
+
+// parseVariantDictionary decodes the typed key/value map KDBX 4 uses for
+// KDF parameters.
+func parseVariantDictionary(data []byte) (map[string]any, error) {
+	if len(data) < 2 || data[1] != 1 {
+		return nil, errors.New("unsupported variant dictionary version")
+	}
+	data = data[2:]
+
+	m := make(map[string]any)
+	for {
+		if len(data) < 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		typ := data[0]
+		if typ == 0 {
+			return m, nil
+		}
+		name, rest, err := readVDItem(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := readVDItem(rest)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		switch typ {
+		case vdUint32, vdInt32:
+			if len(value) != 4 {
+				return nil, fmt.Errorf("variant dictionary: bad %s", name)
+			}
+			if typ == vdUint32 {
+				m[string(name)] = binary.LittleEndian.Uint32(value)
+			} else {
+				m[string(name)] = int32(binary.LittleEndian.Uint32(value))
+			}
+		case vdUint64, vdInt64:
+			if len(value) != 8 {
+				return nil, fmt.Errorf("variant dictionary: bad %s", name)
+			}
+			if typ == vdUint64 {
+				m[string(name)] = binary.LittleEndian.Uint64(value)
+			} else {
+				m[string(name)] = int64(binary.LittleEndian.Uint64(value))
+			}
+		case vdBool:
+			m[string(name)] = len(value) == 1 && value[0] != 0
+		case vdString:
+			m[string(name)] = string(value)
+		case vdBytes:
+			m[string(name)] = value
+		default:
+			return nil, fmt.Errorf("variant dictionary: unknown type %#x", typ)
+		}
+	}
+}
+
+func readVDItem(data []byte) (item, rest []byte, err error) {
+	if len(data) < 4 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n < 0 || len(data)-4 < n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return data[4 : 4+n], data[4+n:], nil
+}
+
+// marshalVariantDictionary encodes uint32, uint64 and []byte values, in
+// the order given by keys.
+func marshalVariantDictionary(keys []string, m map[string]any) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0, 1})
+	for _, k := range keys {
+		var typ byte
+		var value []byte
+		switch v := m[k].(type) {
+		case uint32:
+			typ, value = vdUint32, binary.LittleEndian.AppendUint32(nil, v)
+		case uint64:
+			typ, value = vdUint64, binary.LittleEndian.AppendUint64(nil, v)
+		case []byte:
+			typ, value = vdBytes, v
+		default:
+			continue
+		}
+		b.WriteByte(typ)
+		binary.Write(&b, binary.LittleEndian, int32(len(k)))
+		b.WriteString(k)
+		binary.Write(&b, binary.LittleEndian, int32(len(value)))
+		b.Write(value)
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+// kdbxArgon2Params are the Argon2id settings for new databases.
+type kdbxArgon2Params struct {
+	iterations  uint64
+	memory      uint64 // bytes
+	parallelism uint32
+}
+
+// newKDBX returns an empty KDBX 4 database with AES-256 encryption, gzip
+// compression and an Argon2id KDF.
+func newKDBX(compositeKey []byte, params kdbxArgon2Params, name string) (*kdbxDatabase, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	kdf := map[string]any{
+		"$UUID": kdbxKDFArgon2id,
+		"S":     salt,
+		"I":     params.iterations,
+		"M":     params.memory,
+		"P":     params.parallelism,
+		"V":     uint32(0x13),
+	}
+
+	db := &kdbxDatabase{
+		header: []kdbxField{
+			{kdbxHeaderCipherID, kdbxCipherAES256},
+			{kdbxHeaderCompression, binary.LittleEndian.AppendUint32(nil, 1)},
+			{kdbxHeaderMasterSeed, nil},
+			{kdbxHeaderIV, nil},
+			{kdbxHeaderKDF, marshalVariantDictionary([]string{"$UUID", "S", "I", "M", "P", "V"}, kdf)},
+		},
+	}
+
+	var err error
+	if db.transformedKey, err = kdbxTransformKey(compositeKey, kdf); err != nil {
+		return nil, err
+	}
+
+	root := newKeePassGroup("Root")
+	db.doc = &xmlNode{
+		XMLName: xml.Name{Local: "KeePassFile"},
+		Children: []*xmlNode{
+			{XMLName: xml.Name{Local: "Meta"}, Children: []*xmlNode{
+				textNode("Generator", "git-credentials-org"),
+				textNode("DatabaseName", name),
+				{XMLName: xml.Name{Local: "MemoryProtection"}, Children: []*xmlNode{
+					textNode("ProtectTitle", "False"),
+					textNode("ProtectUserName", "False"),
+					textNode("ProtectPassword", "True"),
+					textNode("ProtectURL", "False"),
+					textNode("ProtectNotes", "False"),
+				}},
+			}},
+			{XMLName: xml.Name{Local: "Root"}, Children: []*xmlNode{
+				root,
+				{XMLName: xml.Name{Local: "DeletedObjects"}},
+			}},
+		},
+	}
+	return db, nil
+}
+
+// xmlNode is a generic XML element, so a database round-trips without this
+// package knowing its full schema.
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []*xmlNode `xml:",any"`
+}
+
+func textNode(name, text string) *xmlNode {
+	return &xmlNode{XMLName: xml.Name{Local: name}, Text: text}
+}
+
+// child returns the first child element called name, or nil.
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.Children {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ensureChild returns the first child called name, appending it if missing.
+func (n *xmlNode) ensureChild(name string) *xmlNode {
+	if c := n.child(name); c != nil {
+		return c
+	}
+	c := &xmlNode{XMLName: xml.Name{Local: name}}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func textOf(n *xmlNode) string {
+	if n == nil {
+		return ""
+	}
+	return n.Text
+}
+
+func setText(n *xmlNode, name, text string) {
+	n.ensureChild(name).Text = text
+}
+
+// insertBefore inserts c before the first child named one of names, or
+// appends it.
+func (n *xmlNode) insertBefore(c *xmlNode, names ...string) {
+	for i, child := range n.Children {
+		for _, name := range names {
+			if child.XMLName.Local == name {
+				n.Children = append(n.Children[:i], append([]*xmlNode{c}, n.Children[i:]...)...)
+				return
+			}
+		}
+	}
+	n.Children = append(n.Children, c)
+}
+
+func (n *xmlNode) remove(c *xmlNode) {
+	for i, child := range n.Children {
+		if child == c {
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// trimSpace drops the indentation between child elements.
+func (n *xmlNode) trimSpace() {
+	if len(n.Children) > 0 && strings.TrimSpace(n.Text) == "" {
+		n.Text = ""
+	}
+	for _, c := range n.Children {
+		c.trimSpace()
+	}
+}
+
+func (n *xmlNode) clone() *xmlNode {
+	c := *n
+	c.Attrs = append([]xml.Attr(nil), n.Attrs...)
+	c.Children = make([]*xmlNode, len(n.Children))
+	for i, child := range n.Children {
+		c.Children[i] = child.clone()
+	}
+	return &c
+}
+
+// protect decrypts (or encrypts) every Protected="True" value in document
+// order with the inner stream cipher.
+func (n *xmlNode) protect(stream cipher.Stream, encrypt bool) error {
+	if n.attr("Protected") == "True" {
+		if encrypt {
+			data := []byte(n.Text)
+			stream.XORKeyStream(data, data)
+			n.Text = base64.StdEncoding.EncodeToString(data)
+		} else {
+			data, err := base64.StdEncoding.DecodeString(n.Text)
+			if err != nil {
+				return fmt.Errorf("decoding protected value: %w", err)
+			}
+			stream.XORKeyStream(data, data)
+			n.Text = string(data)
+		}
+	}
+	for _, c := range n.Children {
+		if err := c.protect(stream, encrypt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
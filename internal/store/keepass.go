@@ -0,0 +1,441 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultKeePassGroup = "Git"
+
+// KeePassStore keeps each namespace as an entry in a KeePass KDBX 4
+// database, with the entry URL set to the namespace, so credentials can
+// live in an existing KeePassXC database without a running KeePassXC.
+// Entries are kept in one group (nested groups separated by "/"), created
+// on first store.
+type KeePassStore struct {
+	path           string
+	group          string
+	keyFile        string
+	passphraseFile string
+
+	// argon2 is the KDF cost for databases created by the store; lowered in
+	// tests.
+	argon2 kdbxArgon2Params
+}
+
+// NewKeePassStore returns a store for the database at path, unlocked with
+// a password from passphraseFile or $GIT_CREDENTIALS_ORG_PASSPHRASE and/or
+// keyFile.
+func NewKeePassStore(path, group, keyFile, passphraseFile string) *KeePassStore {
+	if group == "" {
+		group = defaultKeePassGroup
+	}
+	return &KeePassStore{
+		path:           path,
+		group:          group,
+		keyFile:        keyFile,
+		passphraseFile: passphraseFile,
+		argon2:         kdbxArgon2Params{iterations: 4, memory: 64 << 20, parallelism: 2},
+	}
+}
+
+func (k *KeePassStore) Name() string {
+	return "keepass"
+}
+
+func (k *KeePassStore) Get(namespace string) (*Credential, error) {
+	if _, err := os.Stat(k.path); os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	unlock, err := lockFile(k.path+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("keepass get %q: %w", namespace, err)
+	}
+	defer unlock()
+
+	db, err := k.open()
+	if err != nil {
+		return nil, fmt.Errorf("keepass get %q: %w", namespace, err)
+	}
+
+	group := k.findGroup(db, false)
+	if group == nil {
+		return nil, ErrNotFound
+	}
+	entry := findKeePassEntry(group, namespace)
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+
+	return keePassCredential(entry)
+}
+
+func (k *KeePassStore) Store(namespace string, cred *Credential) error {
+	return k.update(true, func(db *kdbxDatabase) {
+		group := k.findGroup(db, true)
+		entry := findKeePassEntry(group, namespace)
+		now := time.Now()
+		if entry == nil {
+			entry = newKeePassEntry(namespace, now)
+			group.insertBefore(entry, "Group")
+		}
+		setKeePassCredential(entry, cred, now)
+	})
+}
+
+func (k *KeePassStore) Erase(namespace string) error {
+	if _, err := os.Stat(k.path); os.IsNotExist(err) {
+		return nil
+	}
+
+	return k.update(false, func(db *kdbxDatabase) {
+		group := k.findGroup(db, false)
+		if group == nil {
+			return
+		}
+		entry := findKeePassEntry(group, namespace)
+		if entry == nil {
+			return
+		}
+		group.remove(entry)
+
+		// Record the deletion so KeePass synchronization doesn't bring the
+		// entry back from another copy of the database.
+		deleted := db.doc.ensureChild("Root").ensureChild("DeletedObjects")
+		deleted.Children = append(deleted.Children, &xmlNode{
+			XMLName: xml.Name{Local: "DeletedObject"},
+			Children: []*xmlNode{
+				textNode("UUID", textOf(entry.child("UUID"))),
+				textNode("DeletionTime", kdbxTime(time.Now())),
+			},
+		})
+	})
+}
+
+// List returns the namespaces of the entries in the group, sorted.
+func (k *KeePassStore) List() ([]string, error) {
+	if _, err := os.Stat(k.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	unlock, err := lockFile(k.path+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("keepass list: %w", err)
+	}
+	defer unlock()
+
+	db, err := k.open()
+	if err != nil {
+		return nil, fmt.Errorf("keepass list: %w", err)
+	}
+
+	group := k.findGroup(db, false)
+	if group == nil {
+		return nil, nil
+	}
+	var namespaces []string
+	for _, c := range group.Children {
+		if c.XMLName.Local != "Entry" {
+			continue
+		}
+		if ns := entryString(c, "URL"); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// update applies fn to the database under an exclusive lock and writes it
+// back atomically. A missing database is created if create is set.
+func (k *KeePassStore) update(create bool, fn func(*kdbxDatabase)) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return fmt.Errorf("keepass store: creating directory: %w", err)
+	}
+
+	unlock, err := lockFile(k.path+".lock", true)
+	if err != nil {
+		return fmt.Errorf("keepass store: %w", err)
+	}
+	defer unlock()
+
+	db, err := k.open()
+	if os.IsNotExist(err) && create {
+		db, err = k.create()
+	}
+	if err != nil {
+		return fmt.Errorf("keepass store: %w", err)
+	}
+
+	fn(db)
+
+	if err := writeFileAtomic(k.path, db.write); err != nil {
+		return fmt.Errorf("keepass store: %w", err)
+	}
+	return nil
+}
+
+func (k *KeePassStore) open() (*kdbxDatabase, error) {
+	file, err := os.Open(k.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	key, err := k.compositeKey()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := readKDBX(file, key)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", k.path, err)
+	}
+	return db, nil
+}
+
+func (k *KeePassStore) create() (*kdbxDatabase, error) {
+	key, err := k.compositeKey()
+	if err != nil {
+		return nil, err
+	}
+	return newKDBX(key, k.argon2, "git-credentials-org")
+}
+
+// compositeKey combines the configured password and key file.
+func (k *KeePassStore) compositeKey() ([]byte, error) {
+	var keyFile []byte
+	if k.keyFile != "" {
+		data, err := os.ReadFile(k.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+		keyFile = data
+	}
+
+	var password string
+	var hasPassword bool
+	switch {
+	case k.passphraseFile != "":
+		data, err := readPrivateFile(k.passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+		password, hasPassword = strings.TrimRight(string(data), "\r\n"), true
+	case os.Getenv(filePassphraseEnv) != "":
+		password, hasPassword = os.Getenv(filePassphraseEnv), true
+	case keyFile == nil:
+		return nil, errors.New("no password or key file configured (set key_file or passphrase_file in [backends.keepass], or " + filePassphraseEnv + ")")
+	}
+
+	return kdbxCompositeKey(password, hasPassword, keyFile)
+}
+
+// findGroup returns the configured group below the root group, creating
+// missing groups if create is set; otherwise it returns nil for them.
+func (k *KeePassStore) findGroup(db *kdbxDatabase, create bool) *xmlNode {
+	root := db.doc.ensureChild("Root")
+	group := root.child("Group")
+	if group == nil {
+		if !create {
+			return nil
+		}
+		group = newKeePassGroup("Root")
+		root.Children = append([]*xmlNode{group}, root.Children...)
+	}
+
+	for _, name := range strings.Split(strings.Trim(k.group, "/"), "/") {
+		var next *xmlNode
+		for _, c := range group.Children {
+			if c.XMLName.Local == "Group" && textOf(c.child("Name")) == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			if !create {
+				return nil
+			}
+			next = newKeePassGroup(name)
+			group.Children = append(group.Children, next)
+		}
+		group = next
+	}
+	return group
+}
+
+// findKeePassEntry returns the entry in group whose URL is namespace.
+func findKeePassEntry(group *xmlNode, namespace string) *xmlNode {
+	for _, c := range group.Children {
+		if c.XMLName.Local == "Entry" && entryString(c, "URL") == namespace {
+			return c
+		}
+	}
+	return nil
+}
+
+func keePassCredential(entry *xmlNode) (*Credential, error) {
+	cred := &Credential{
+		Username:          entryString(entry, "UserName"),
+		Password:          entryString(entry, "Password"),
+		OAuthRefreshToken: entryString(entry, fieldRefreshToken),
+		AuthType:          entryString(entry, fieldAuthType),
+	}
+	if expiry := entryString(entry, fieldExpiry); expiry != "" {
+		t, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing keepass %s: %w", fieldExpiry, err)
+		}
+		cred.PasswordExpiryUTC = t
+	}
+	for _, f := range metadataFields(cred) {
+		setMetadataField(cred, f.name, entryString(entry, f.name))
+	}
+
+	if cred.Password == "" {
+		return nil, ErrNotFound
+	}
+	return cred, nil
+}
+
+// setKeePassCredential writes cred into entry. The expiry is also set as
+// the entry's KeePass expiry so KeePassXC shows it.
+func setKeePassCredential(entry *xmlNode, cred *Credential, now time.Time) {
+	setEntryString(entry, "UserName", cred.Username, false)
+	setEntryString(entry, "Password", cred.Password, true)
+	setEntryString(entry, fieldExpiry, formatUnix(cred.PasswordExpiryUTC), false)
+	setEntryString(entry, fieldRefreshToken, cred.OAuthRefreshToken, true)
+	setEntryString(entry, fieldAuthType, cred.AuthType, false)
+	for _, f := range metadataFields(cred) {
+		setEntryString(entry, f.name, f.value, false)
+	}
+
+	times := entry.ensureChild("Times")
+	setText(times, "LastModificationTime", kdbxTime(now))
+	if cred.PasswordExpiryUTC != 0 {
+		setText(times, "Expires", "True")
+		setText(times, "ExpiryTime", kdbxTime(time.Unix(cred.PasswordExpiryUTC, 0)))
+	} else {
+		setText(times, "Expires", "False")
+	}
+}
+
+// keePassStandardFields always exist on an entry, even when empty.
+var keePassStandardFields = []string{"Title", "UserName", "Password", "URL", "Notes"}
+
+func entryString(entry *xmlNode, key string) string {
+	if s := findEntryString(entry, key); s != nil {
+		return textOf(s.child("Value"))
+	}
+	return ""
+}
+
+func findEntryString(entry *xmlNode, key string) *xmlNode {
+	for _, c := range entry.Children {
+		if c.XMLName.Local == "String" && textOf(c.child("Key")) == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// setEntryString sets a string field of an entry. Empty custom fields are
+// removed rather than kept empty.
+func setEntryString(entry *xmlNode, key, value string, protected bool) {
+	s := findEntryString(entry, key)
+	if value == "" {
+		standard := false
+		for _, f := range keePassStandardFields {
+			standard = standard || f == key
+		}
+		if !standard {
+			if s != nil {
+				entry.remove(s)
+			}
+			return
+		}
+	}
+
+	if s == nil {
+		s = &xmlNode{XMLName: xml.Name{Local: "String"}, Children: []*xmlNode{textNode("Key", key), textNode("Value", "")}}
+		entry.insertBefore(s, "AutoType", "History")
+	}
+	v := s.ensureChild("Value")
+	v.Text = value
+	if protected && v.attr("Protected") != "True" {
+		v.Attrs = append(v.Attrs, xml.Attr{Name: xml.Name{Local: "Protected"}, Value: "True"})
+	}
+}
+
+func newKeePassEntry(namespace string, now time.Time) *xmlNode {
+	entry := &xmlNode{
+		XMLName: xml.Name{Local: "Entry"},
+		Children: []*xmlNode{
+			textNode("UUID", newKeePassUUID()),
+			textNode("IconID", "0"),
+			newKeePassTimes(now),
+		},
+	}
+	for _, f := range keePassStandardFields {
+		setEntryString(entry, f, "", f == "Password")
+	}
+	setEntryString(entry, "Title", itemTitle(namespace), false)
+	setEntryString(entry, "URL", namespace, false)
+	return entry
+}
+
+func newKeePassGroup(name string) *xmlNode {
+	return &xmlNode{
+		XMLName: xml.Name{Local: "Group"},
+		Children: []*xmlNode{
+			textNode("UUID", newKeePassUUID()),
+			textNode("Name", name),
+			textNode("IconID", "48"),
+			newKeePassTimes(time.Now()),
+			textNode("IsExpanded", "True"),
+		},
+	}
+}
+
+func newKeePassTimes(now time.Time) *xmlNode {
+	t := kdbxTime(now)
+	return &xmlNode{
+		XMLName: xml.Name{Local: "Times"},
+		Children: []*xmlNode{
+			textNode("LastModificationTime", t),
+			textNode("CreationTime", t),
+			textNode("LastAccessTime", t),
+			textNode("ExpiryTime", t),
+			textNode("Expires", "False"),
+			textNode("UsageCount", "0"),
+			textNode("LocationChanged", t),
+		},
+	}
+}
+
+func newKeePassUUID() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return base64.StdEncoding.EncodeToString(uuid[:])
+}
+
+// kdbxTime encodes a time as KDBX 4 does: base64 of the little-endian
+// seconds since 0001-01-01 UTC.
+func kdbxTime(t time.Time) string {
+	const unixToKDBX = 62135596800
+	secs := uint64(t.Unix() + unixToKDBX)
+	return base64.StdEncoding.EncodeToString(binary.LittleEndian.AppendUint64(nil, secs))
+}
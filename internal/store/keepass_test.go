@@ -0,0 +1,353 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testArgon2 keeps key derivation fast in tests.
+var testArgon2 = kdbxArgon2Params{iterations: 1, memory: 1 << 20, parallelism: 1}
+
+func newTestKeePassStore(t *testing.T, path, group, keyFile string) *KeePassStore {
+	t.Helper()
+	k := NewKeePassStore(path, group, keyFile, "")
+	k.argon2 = testArgon2
+	return k
+}
+
+func TestKeePassStoreRoundTrip(t *testing.T) {
+	t.Setenv(filePassphraseEnv, "correct horse")
+	path := filepath.Join(t.TempDir(), "db", "passwords.kdbx")
+	k := newTestKeePassStore(t, path, "Dev/Git", "")
+
+	if _, err := k.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before any store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000, OAuthRefreshToken: "rt"}
+	if err := k.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := k.Store("github.com/acme", &Credential{Username: "x-access-token", Password: "ghp_x"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got, err := k.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", *got, *cred)
+	}
+
+	if got, err := k.List(); err != nil || !slices.Equal(got, []string{"github.com/acme", "gitlab.com/org1"}) {
+		t.Errorf("List() = %v, %v", got, err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if bytes.Contains(raw, []byte("glpat-abc")) {
+		t.Error("database contains the plaintext secret")
+	}
+
+	updated := &Credential{Username: "oauth2", Password: "glpat-new"}
+	if err := k.Store("gitlab.com/org1", updated); err != nil {
+		t.Fatalf("Store() update error = %v", err)
+	}
+	got, err = k.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() after update error = %v", err)
+	}
+	if *got != *updated {
+		t.Errorf("Get() after update = %+v, want %+v", *got, *updated)
+	}
+
+	if err := k.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := k.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+	if _, err := k.Get("github.com/acme"); err != nil {
+		t.Errorf("Get() of other namespace after Erase() error = %v", err)
+	}
+
+	db := openTestKDBX(t, path, "correct horse", nil)
+	group := k.findGroup(db, false)
+	if group == nil {
+		t.Fatal("group Dev/Git not found")
+	}
+	entry := findKeePassEntry(group, "github.com/acme")
+	if entry == nil {
+		t.Fatal("entry with URL github.com/acme not found")
+	}
+	if title := entryString(entry, "Title"); title != "git-credentials-org: github.com/acme" {
+		t.Errorf("entry title = %q", title)
+	}
+	if deleted := db.doc.child("Root").child("DeletedObjects"); deleted == nil || len(deleted.Children) != 1 {
+		t.Errorf("DeletedObjects = %+v, want one record", deleted)
+	}
+}
+
+func TestKeePassStorePreservesDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.kdbx")
+	key, _ := kdbxCompositeKey("pw", true, nil)
+
+	// A database with an unrelated entry holding protected values and an
+	// attachment, as KeePassXC would have it.
+	db, err := newKDBX(key, testArgon2, "Personal")
+	if err != nil {
+		t.Fatalf("newKDBX() error = %v", err)
+	}
+	db.binaries = [][]byte{append([]byte{1}, "attachment"...)}
+	root := db.doc.child("Root").child("Group")
+	bank := newKeePassEntry("bank.example.com", time.Now())
+	setEntryString(bank, "Password", "hunter2", true)
+	setEntryString(bank, "PIN", "1234", true)
+	root.insertBefore(bank, "Group")
+	writeTestKDBX(t, path, db)
+
+	t.Setenv(filePassphraseEnv, "pw")
+	k := newTestKeePassStore(t, path, "", "")
+	if err := k.Store("gitlab.com/org1", &Credential{Username: "oauth2", Password: "glpat-abc", OAuthRefreshToken: "rt"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	db = openTestKDBX(t, path, "pw", nil)
+	if name := textOf(db.doc.child("Meta").child("DatabaseName")); name != "Personal" {
+		t.Errorf("DatabaseName = %q, want Personal", name)
+	}
+	if len(db.binaries) != 1 || string(db.binaries[0][1:]) != "attachment" {
+		t.Errorf("binaries = %q, want attachment kept", db.binaries)
+	}
+	bank = findKeePassEntry(db.doc.child("Root").child("Group"), "bank.example.com")
+	if bank == nil {
+		t.Fatal("unrelated entry lost")
+	}
+	if got := entryString(bank, "Password"); got != "hunter2" {
+		t.Errorf("unrelated Password = %q, want hunter2", got)
+	}
+	if got := entryString(bank, "PIN"); got != "1234" {
+		t.Errorf("unrelated PIN = %q, want 1234", got)
+	}
+
+	entry := findKeePassEntry(k.findGroup(db, false), "gitlab.com/org1")
+	if entry == nil {
+		t.Fatal("stored entry not found in group Git")
+	}
+	for _, field := range []string{"Password", fieldRefreshToken} {
+		if v := findEntryString(entry, field).child("Value"); v.attr("Protected") != "True" {
+			t.Errorf("%s not protected", field)
+		}
+	}
+}
+
+func TestKeePassStoreKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "passwords.kdbx")
+
+	// KeePassXC's XML key file format, version 2.0.
+	raw := bytes.Repeat([]byte{0xAB}, 32)
+	sum := sha256.Sum256(raw)
+	keyFile := filepath.Join(dir, "passwords.keyx")
+	keyXML := `<?xml version="1.0" encoding="utf-8"?>
+<KeyFile>
+	<Meta><Version>2.0</Version></Meta>
+	<Key><Data Hash="` + strings.ToUpper(hex.EncodeToString(sum[:4])) + `">
+		` + strings.ToUpper(hex.EncodeToString(raw[:16])) + `
+		` + strings.ToUpper(hex.EncodeToString(raw[16:])) + `
+	</Data></Key>
+</KeyFile>`
+	if err := os.WriteFile(keyFile, []byte(keyXML), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	t.Setenv(filePassphraseEnv, "")
+	k := newTestKeePassStore(t, path, "", keyFile)
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc"}
+	if err := k.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if got, err := k.Get("gitlab.com/org1"); err != nil || *got != *cred {
+		t.Errorf("Get() = %+v, %v, want %+v", got, err, cred)
+	}
+
+	// The key file's key is used directly, like a raw 32-byte key file.
+	openTestKDBX(t, path, "", raw)
+
+	t.Setenv(filePassphraseEnv, "not the password")
+	if _, err := k.Get("gitlab.com/org1"); err == nil || !strings.Contains(err.Error(), "wrong password or key file") {
+		t.Errorf("Get() with wrong password error = %v, want wrong password", err)
+	}
+}
+
+func TestKDBXCiphersAndKDFs(t *testing.T) {
+	key, _ := kdbxCompositeKey("pw", true, nil)
+	aesKDF := marshalVariantDictionary([]string{"$UUID", "S", "R"}, map[string]any{
+		"$UUID": kdbxKDFAES,
+		"S":     bytes.Repeat([]byte{7}, 32),
+		"R":     uint64(100),
+	})
+	// KeePassXC's default KDF, with the parameter layout it writes.
+	argon2dKDF := marshalVariantDictionary([]string{"$UUID", "I", "M", "P", "S", "V"}, map[string]any{
+		"$UUID": kdbxKDFArgon2d,
+		"I":     uint64(2),
+		"M":     uint64(1 << 20),
+		"P":     uint32(2),
+		"S":     bytes.Repeat([]byte{9}, 32),
+		"V":     uint32(0x13),
+	})
+
+	tests := []struct {
+		name   string
+		cipher []byte
+		kdf    []byte
+	}{
+		{"aes/argon2id", kdbxCipherAES256, nil},
+		{"chacha20/argon2id", kdbxCipherChaCha20, nil},
+		{"aes/argon2d", kdbxCipherAES256, argon2dKDF},
+		{"chacha20/argon2d", kdbxCipherChaCha20, argon2dKDF},
+		{"twofish/aes-kdf", kdbxCipherTwofish, aesKDF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := newKDBX(key, testArgon2, "test")
+			if err != nil {
+				t.Fatalf("newKDBX() error = %v", err)
+			}
+			db.setField(kdbxHeaderCipherID, tt.cipher)
+			if tt.kdf != nil {
+				params, _ := parseVariantDictionary(tt.kdf)
+				db.setField(kdbxHeaderKDF, tt.kdf)
+				if db.transformedKey, err = kdbxTransformKey(key, params); err != nil {
+					t.Fatalf("kdbxTransformKey() error = %v", err)
+				}
+			}
+			entry := newKeePassEntry("gitlab.com/org1", time.Now())
+			setEntryString(entry, "Password", "glpat-abc", true)
+			db.doc.child("Root").child("Group").insertBefore(entry, "Group")
+
+			var buf bytes.Buffer
+			if err := db.write(&buf); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+			got, err := readKDBX(&buf, key)
+			if err != nil {
+				t.Fatalf("readKDBX() error = %v", err)
+			}
+			if pw := entryString(findKeePassEntry(got.doc.child("Root").child("Group"), "gitlab.com/org1"), "Password"); pw != "glpat-abc" {
+				t.Errorf("Password = %q, want glpat-abc", pw)
+			}
+
+			// Writing back keeps the database's cipher and KDF.
+			buf.Reset()
+			if err := got.write(&buf); err != nil {
+				t.Fatalf("write() of read database error = %v", err)
+			}
+			again, err := readKDBX(&buf, key)
+			if err != nil {
+				t.Fatalf("readKDBX() of written back database error = %v", err)
+			}
+			if !bytes.Equal(again.field(kdbxHeaderCipherID), tt.cipher) || (tt.kdf != nil && !bytes.Equal(again.field(kdbxHeaderKDF), tt.kdf)) {
+				t.Error("writing back changed the cipher or KDF")
+			}
+		})
+	}
+}
+
+func TestKDBXArgon2SecretKey(t *testing.T) {
+	params := map[string]any{
+		"$UUID": kdbxKDFArgon2d, "S": []byte("salt-salt-salt-s"), "I": uint64(1), "M": uint64(64 << 10), "P": uint32(1), "V": uint32(0x13),
+	}
+	plain, err := kdbxTransformKey([]byte("key"), params)
+	if err != nil {
+		t.Fatalf("kdbxTransformKey(Argon2d) error = %v", err)
+	}
+	params["K"] = []byte("secret")
+	withSecret, err := kdbxTransformKey([]byte("key"), params)
+	if err != nil || bytes.Equal(plain, withSecret) {
+		t.Errorf("kdbxTransformKey(Argon2d, K) = %x, %v, want a different key", withSecret, err)
+	}
+
+	params["$UUID"] = kdbxKDFArgon2id
+	if _, err := kdbxTransformKey([]byte("key"), params); err == nil {
+		t.Error("kdbxTransformKey(Argon2id, K) should fail")
+	}
+}
+
+func TestKDBXKeyFileKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0x11}, 32)
+	hashed := sha256.Sum256([]byte("any file"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"raw 32 bytes", raw, raw},
+		{"64 hex digits", []byte(hex.EncodeToString(raw)), raw},
+		{"xml 1.0", []byte("<KeyFile><Meta><Version>1.00</Version></Meta><Key><Data>" + base64.StdEncoding.EncodeToString(raw) + "</Data></Key></KeyFile>"), raw},
+		{"other file", []byte("any file"), hashed[:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kdbxKeyFileKey(tt.data)
+			if err != nil {
+				t.Fatalf("kdbxKeyFileKey() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("kdbxKeyFileKey() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKDBXTime(t *testing.T) {
+	// 2024-01-01T00:00:00Z is 63839664000 seconds after 0001-01-01.
+	data, _ := base64.StdEncoding.DecodeString(kdbxTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	var want [8]byte
+	for i, v := 0, uint64(63839664000); i < 8; i, v = i+1, v>>8 {
+		want[i] = byte(v)
+	}
+	if !bytes.Equal(data, want[:]) {
+		t.Errorf("kdbxTime() = %x, want %x", data, want)
+	}
+}
+
+func writeTestKDBX(t *testing.T, path string, db *kdbxDatabase) {
+	t.Helper()
+	if err := writeFileAtomic(path, db.write); err != nil {
+		t.Fatalf("writing database: %v", err)
+	}
+}
+
+func openTestKDBX(t *testing.T, path, password string, keyFile []byte) *kdbxDatabase {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	key, err := kdbxCompositeKey(password, password != "", keyFile)
+	if err != nil {
+		t.Fatalf("kdbxCompositeKey() error = %v", err)
+	}
+	db, err := readKDBX(f, key)
+	if err != nil {
+		t.Fatalf("readKDBX() error = %v", err)
+	}
+	return db
+}
@@ -1,8 +1,13 @@
 package store
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/zalando/go-keyring"
 )
@@ -10,10 +15,14 @@ import (
 const keychainServicePrefix = "git-credentials-org"
 const keychainAccount = "credentials"
 
-type KeychainStore struct{}
+type KeychainStore struct {
+	// services lists the keyring's service names for our account; it is
+	// platform specific and replaced in tests.
+	services func() ([]string, error)
+}
 
 func NewKeychainStore() *KeychainStore {
-	return &KeychainStore{}
+	return &KeychainStore{services: listKeychainServices}
 }
 
 func (k *KeychainStore) Name() string {
@@ -65,3 +74,78 @@ func (k *KeychainStore) Erase(namespace string) error {
 	}
 	return nil
 }
+
+// List returns the namespaces saved in the keychain, sorted.
+func (k *KeychainStore) List() ([]string, error) {
+	services, err := k.services()
+	if err != nil {
+		return nil, fmt.Errorf("keychain list: %w", err)
+	}
+
+	var namespaces []string
+	for _, service := range services {
+		ns, ok := strings.CutPrefix(service, keychainServicePrefix+":")
+		if ok && ns != "" && !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// keychainDumpServices returns the services of the generic passwords for
+// our account in `security dump-keychain` output.
+func keychainDumpServices(dump []byte) []string {
+	var services []string
+	for _, item := range ParseKeychainDump(dump, "genp") {
+		if item["acct"] == keychainAccount && item["svce"] != "" {
+			services = append(services, item["svce"])
+		}
+	}
+	return services
+}
+
+// keychainAttr matches an attribute line of `security dump-keychain`, e.g.
+// `    "srvr"<blob>="github.com"`.
+var keychainAttr = regexp.MustCompile(`^\s+"(\w{4})"<\w+>=(.*)$`)
+
+// ParseKeychainDump returns the attributes of the items of class (e.g.
+// "genp" for generic and "inet" for internet passwords) in
+// `security dump-keychain` output.
+func ParseKeychainDump(data []byte, class string) []map[string]string {
+	var items []map[string]string
+	var item map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "class: ") {
+			item = nil
+			if line == `class: "`+class+`"` {
+				item = make(map[string]string)
+				items = append(items, item)
+			}
+			continue
+		}
+		if item == nil {
+			continue
+		}
+		if m := keychainAttr.FindStringSubmatch(line); m != nil {
+			item[m[1]] = keychainValue(m[2])
+		}
+	}
+	return items
+}
+
+// keychainValue decodes an attribute value: `"text"`, `0x...  "text"` for
+// values with non-printable bytes, a bare number, or <NULL>.
+func keychainValue(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "<NULL>" {
+		return ""
+	}
+	if i := strings.Index(v, `"`); i >= 0 && strings.HasSuffix(v, `"`) && len(v) > i+1 {
+		return strings.TrimSuffix(v[i+1:len(v)-1], `\000`)
+	}
+	return v
+}
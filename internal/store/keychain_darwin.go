@@ -0,0 +1,22 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// listKeychainServices reads the item attributes (not the secrets) of the
+// default keychains, where go-keyring saves generic passwords.
+func listKeychainServices() ([]string, error) {
+	cmd := exec.Command("/usr/bin/security", "dump-keychain")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("security dump-keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return keychainDumpServices(stdout.Bytes()), nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package store
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func listKeychainServices() ([]string, error) {
+	return nil, fmt.Errorf("listing the keyring is not supported on %s", runtime.GOOS)
+}
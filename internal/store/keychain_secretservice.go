@@ -0,0 +1,38 @@
+//go:build unix && !darwin
+
+package store
+
+import (
+	"fmt"
+)
+
+// listKeychainServices searches the Secret Service collection go-keyring
+// uses (login, else the default one) for our account's items.
+func listKeychainServices() ([]string, error) {
+	svc, err := connectSecretService()
+	if err != nil {
+		return nil, err
+	}
+	defer svc.Close()
+	return searchKeychainServices(svc)
+}
+
+func searchKeychainServices(svc secretService) ([]string, error) {
+	attrs := map[string]string{"username": keychainAccount}
+	items, err := svc.Search("login", attrs)
+	if err != nil {
+		if items, err = svc.Search("default", attrs); err != nil {
+			return nil, err
+		}
+	}
+
+	var services []string
+	for _, item := range items {
+		itemAttrs, err := svc.Attributes(item)
+		if err != nil {
+			return nil, fmt.Errorf("reading item attributes: %w", err)
+		}
+		services = append(services, itemAttrs["service"])
+	}
+	return services, nil
+}
@@ -0,0 +1,22 @@
+//go:build unix && !darwin
+
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchKeychainServices(t *testing.T) {
+	fake := newFakeSecretService()
+	fake.CreateItem("login", "git-credentials-org:gitlab.com/org1", map[string]string{"service": "git-credentials-org:gitlab.com/org1", "username": "credentials"}, []byte("{}"))
+	fake.CreateItem("login", "Chrome Safe Storage", map[string]string{"application": "chrome"}, []byte("x"))
+
+	got, err := searchKeychainServices(fake)
+	if err != nil {
+		t.Fatalf("searchKeychainServices() error = %v", err)
+	}
+	if want := []string{"git-credentials-org:gitlab.com/org1"}; !slices.Equal(got, want) {
+		t.Errorf("searchKeychainServices() = %q, want %q", got, want)
+	}
+}
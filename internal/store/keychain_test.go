@@ -0,0 +1,64 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+const keychainDump = `keychain: "/Users/me/Library/Keychains/login.keychain-db"
+version: 512
+class: "genp"
+attributes:
+    "acct"<blob>="credentials"
+    "svce"<blob>="git-credentials-org:gitlab.com/org1"
+keychain: "/Users/me/Library/Keychains/login.keychain-db"
+version: 512
+class: "genp"
+attributes:
+    "acct"<blob>="me@example.com"
+    "svce"<blob>="Slack Safe Storage"
+keychain: "/Users/me/Library/Keychains/login.keychain-db"
+version: 512
+class: "inet"
+attributes:
+    "acct"<blob>="credentials"
+    "srvr"<blob>="github.com"
+keychain: "/Users/me/Library/Keychains/login.keychain-db"
+version: 512
+class: "genp"
+attributes:
+    "acct"<blob>="credentials"
+    "svce"<blob>=0x6769742D63726564656E7469616C732D6F72673A61636D65  "git-credentials-org:acme"
+`
+
+func TestKeychainDumpServices(t *testing.T) {
+	got := keychainDumpServices([]byte(keychainDump))
+	want := []string{"git-credentials-org:gitlab.com/org1", "git-credentials-org:acme"}
+	if !slices.Equal(got, want) {
+		t.Errorf("keychainDumpServices() = %q, want %q", got, want)
+	}
+}
+
+func TestKeychainList(t *testing.T) {
+	// keychain is the default backend, so list and migrate need it.
+	if _, ok := CredentialStore(NewKeychainStore()).(Lister); !ok {
+		t.Fatal("KeychainStore doesn't implement Lister")
+	}
+
+	k := &KeychainStore{services: func() ([]string, error) {
+		return []string{"git-credentials-org:gitlab.com/org2", "other:github.com", "git-credentials-org:gitlab.com/org1", "git-credentials-org:gitlab.com/org2"}, nil
+	}}
+	got, err := k.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"gitlab.com/org1", "gitlab.com/org2"}; !slices.Equal(got, want) {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+
+	k.services = func() ([]string, error) { return nil, errors.New("locked") }
+	if _, err := k.List(); err == nil {
+		t.Error("List() should fail when the keyring can't be read")
+	}
+}
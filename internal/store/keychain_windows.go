@@ -0,0 +1,24 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/danieljoos/wincred"
+)
+
+// listKeychainServices lists the Credential Manager targets go-keyring
+// creates, "<service>:<account>".
+func listKeychainServices() ([]string, error) {
+	creds, err := wincred.FilteredList(keychainServicePrefix + ":*")
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	for _, cred := range creds {
+		if service, ok := strings.CutSuffix(cred.TargetName, ":"+keychainAccount); ok {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
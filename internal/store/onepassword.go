@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -21,12 +23,8 @@ func (o *OnePasswordStore) Name() string {
 	return "onepassword"
 }
 
-func (o *OnePasswordStore) itemTitle(namespace string) string {
-	return "git-credentials-org: " + namespace
-}
-
 func (o *OnePasswordStore) Get(namespace string) (*Credential, error) {
-	title := o.itemTitle(namespace)
+	title := itemTitle(namespace)
 
 	args := []string{"item", "get", title, "--vault", o.vault, "--format", "json"}
 	if o.account != "" {
@@ -46,7 +44,7 @@ func (o *OnePasswordStore) Get(namespace string) (*Credential, error) {
 }
 
 func (o *OnePasswordStore) Store(namespace string, cred *Credential) error {
-	title := o.itemTitle(namespace)
+	title := itemTitle(namespace)
 
 	existing, err := o.Get(namespace)
 	if err != nil && err != ErrNotFound {
@@ -60,7 +58,7 @@ func (o *OnePasswordStore) Store(namespace string, cred *Credential) error {
 }
 
 func (o *OnePasswordStore) Erase(namespace string) error {
-	title := o.itemTitle(namespace)
+	title := itemTitle(namespace)
 
 	args := []string{"item", "delete", title, "--vault", o.vault}
 	if o.account != "" {
@@ -78,20 +76,53 @@ func (o *OnePasswordStore) Erase(namespace string) error {
 	return nil
 }
 
+// List returns the namespaces of the items in the vault titled with our
+// prefix, sorted.
+func (o *OnePasswordStore) List() ([]string, error) {
+	args := []string{"item", "list", "--vault", o.vault, "--categories", "Login", "--format", "json"}
+	if o.account != "" {
+		args = append(args, "--account", o.account)
+	}
+
+	out, err := o.run(args...)
+	if err != nil {
+		return nil, fmt.Errorf("1password list: %w", err)
+	}
+	return parseItemList(out)
+}
+
+// parseItemList returns the namespaces of our items in "op item list"
+// output.
+func parseItemList(data []byte) ([]string, error) {
+	var items []struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("parsing 1password item list: %w", err)
+	}
+
+	var namespaces []string
+	for _, item := range items {
+		if ns, ok := strings.CutPrefix(item.Title, itemTitlePrefix); ok {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
 func (o *OnePasswordStore) createItem(title string, cred *Credential) error {
 	args := []string{
 		"item", "create",
 		"--category", "login",
 		"--title", title,
 		"--vault", o.vault,
-		"--", // separator for field assignments
-		fmt.Sprintf("username=%s", cred.Username),
-		fmt.Sprintf("password=%s", cred.Password),
 	}
 	if o.account != "" {
-		// insert before the -- separator
-		args = append(args[:7], append([]string{"--account", o.account}, args[7:]...)...)
+		args = append(args, "--account", o.account)
 	}
+	args = append(args, "--") // separator for field assignments
+	args = append(args, o.fieldAssignments(cred)...)
 
 	_, err := o.run(args...)
 	if err != nil {
@@ -104,13 +135,12 @@ func (o *OnePasswordStore) editItem(title string, cred *Credential) error {
 	args := []string{
 		"item", "edit", title,
 		"--vault", o.vault,
-		"--", // separator for field assignments
-		fmt.Sprintf("username=%s", cred.Username),
-		fmt.Sprintf("password=%s", cred.Password),
 	}
 	if o.account != "" {
-		args = append(args[:5], append([]string{"--account", o.account}, args[5:]...)...)
+		args = append(args, "--account", o.account)
 	}
+	args = append(args, "--") // separator for field assignments
+	args = append(args, o.fieldAssignments(cred)...)
 
 	_, err := o.run(args...)
 	if err != nil {
@@ -119,6 +149,23 @@ func (o *OnePasswordStore) editItem(title string, cred *Credential) error {
 	return nil
 }
 
+// fieldAssignments returns the op field assignments for a credential.
+// Optional fields are always assigned (possibly empty) so that editing an
+// item clears values that are no longer present.
+func (o *OnePasswordStore) fieldAssignments(cred *Credential) []string {
+	assignments := []string{
+		fmt.Sprintf("username=%s", cred.Username),
+		fmt.Sprintf("password=%s", cred.Password),
+		fmt.Sprintf("%s[text]=%s", fieldExpiry, formatUnix(cred.PasswordExpiryUTC)),
+		fmt.Sprintf("%s[concealed]=%s", fieldRefreshToken, cred.OAuthRefreshToken),
+		fmt.Sprintf("%s[text]=%s", fieldAuthType, cred.AuthType),
+	}
+	for _, f := range metadataFields(cred) {
+		assignments = append(assignments, fmt.Sprintf("%s[text]=%s", f.name, f.value))
+	}
+	return assignments
+}
+
 func (o *OnePasswordStore) run(args ...string) ([]byte, error) {
 	cmd := exec.Command("op", args...)
 	var stdout, stderr bytes.Buffer
@@ -156,6 +203,24 @@ func (o *OnePasswordStore) parseItemJSON(data []byte) (*Credential, error) {
 		case "password":
 			cred.Password = f.Value
 		}
+
+		switch f.Label {
+		case fieldExpiry:
+			if f.Value == "" {
+				continue
+			}
+			expiry, err := strconv.ParseInt(f.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing 1password %s field: %w", fieldExpiry, err)
+			}
+			cred.PasswordExpiryUTC = expiry
+		case fieldRefreshToken:
+			cred.OAuthRefreshToken = f.Value
+		case fieldAuthType:
+			cred.AuthType = f.Value
+		default:
+			setMetadataField(cred, f.Label, f.Value)
+		}
 	}
 
 	if cred.Username == "" && cred.Password == "" {
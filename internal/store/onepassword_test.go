@@ -0,0 +1,22 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseItemList(t *testing.T) {
+	data := []byte(`[
+		{"id": "a", "title": "git-credentials-org: gitlab.com/org2"},
+		{"id": "b", "title": "GitLab personal"},
+		{"id": "c", "title": "git-credentials-org: github.com/acme"}
+	]`)
+
+	got, err := parseItemList(data)
+	if err != nil {
+		t.Fatalf("parseItemList() error = %v", err)
+	}
+	if want := []string{"github.com/acme", "gitlab.com/org2"}; !slices.Equal(got, want) {
+		t.Errorf("parseItemList() = %v, want %v", got, want)
+	}
+}
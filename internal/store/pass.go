@@ -0,0 +1,255 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const defaultPassPathTemplate = "git/{namespace}"
+
+// PassStore keeps each namespace as a password-store entry, shelling out
+// to pass (or a compatible CLI such as gopass). The password is the first
+// line of the entry; the rest are "key: value" metadata lines:
+//
+//	glpat-abc123
+//	username: oauth2
+//	expiry: 1700000000
+type PassStore struct {
+	command      string
+	pathTemplate string
+}
+
+// NewPassStore returns a store running command ("pass" if empty) with
+// entries at pathTemplate, where {namespace} is substituted.
+func NewPassStore(command, pathTemplate string) *PassStore {
+	if command == "" {
+		command = "pass"
+	}
+	if pathTemplate == "" {
+		pathTemplate = defaultPassPathTemplate
+	}
+	return &PassStore{command: command, pathTemplate: pathTemplate}
+}
+
+func (p *PassStore) Name() string {
+	return "pass"
+}
+
+func (p *PassStore) entryPath(namespace string) string {
+	return strings.ReplaceAll(p.pathTemplate, "{namespace}", namespace)
+}
+
+func (p *PassStore) Get(namespace string) (*Credential, error) {
+	out, err := p.run(nil, "show", p.entryPath(namespace))
+	if err != nil {
+		if isPassNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("pass get %q: %w", namespace, err)
+	}
+
+	return parsePassEntry(out)
+}
+
+func (p *PassStore) Store(namespace string, cred *Credential) error {
+	_, err := p.run(formatPassEntry(cred), "insert", "--multiline", "--force", p.entryPath(namespace))
+	if err != nil {
+		return fmt.Errorf("pass store %q: %w", namespace, err)
+	}
+	return nil
+}
+
+func (p *PassStore) Erase(namespace string) error {
+	_, err := p.run(nil, "rm", "--force", p.entryPath(namespace))
+	if err != nil {
+		if isPassNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("pass erase %q: %w", namespace, err)
+	}
+	return nil
+}
+
+// List returns the namespaces of the entries matching the path template,
+// sorted. Entries are read from gopass's flat listing, or from pass's
+// store directory ($PASSWORD_STORE_DIR or ~/.password-store).
+func (p *PassStore) List() ([]string, error) {
+	entries, err := p.entries()
+	if err != nil {
+		return nil, fmt.Errorf("pass list: %w", err)
+	}
+
+	prefix, suffix, _ := strings.Cut(p.pathTemplate, "{namespace}")
+	var namespaces []string
+	for _, entry := range entries {
+		if len(entry) <= len(prefix)+len(suffix) || !strings.HasPrefix(entry, prefix) || !strings.HasSuffix(entry, suffix) {
+			continue
+		}
+		ns := entry[len(prefix) : len(entry)-len(suffix)]
+		if !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// entries returns the paths of all entries, like "git/gitlab.com/org1".
+func (p *PassStore) entries() ([]string, error) {
+	if filepath.Base(p.command) == "gopass" {
+		out, err := p.run(nil, "ls", "--flat")
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(string(out)), nil
+	}
+
+	dir := os.Getenv("PASSWORD_STORE_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".password-store")
+	}
+
+	var entries []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir // .git, .extensions
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".gpg") {
+			rel, err := filepath.Rel(dir, strings.TrimSuffix(path, ".gpg"))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+func (p *PassStore) run(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(p.command, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// isPassNotFound matches the "Error: git/x is not in the password store."
+// of pass and the "entry is not in the password store" of gopass. Other
+// failures, including a missing pass binary or gpg errors, are real errors.
+func isPassNotFound(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "is not in the password store")
+}
+
+func formatPassEntry(cred *Credential) []byte {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, cred.Password)
+
+	meta := []struct{ key, val string }{
+		{"username", cred.Username},
+		{"expiry", formatUnix(cred.PasswordExpiryUTC)},
+		{"oauth_refresh_token", cred.OAuthRefreshToken},
+		{"authtype", cred.AuthType},
+	}
+	for _, f := range metadataFields(cred) {
+		meta = append(meta, struct{ key, val string }{f.name, f.value})
+	}
+	for _, m := range meta {
+		if m.val != "" {
+			fmt.Fprintf(&b, "%s: %s\n", m.key, m.val)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func parsePassEntry(data []byte) (*Credential, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	cred := &Credential{}
+	if scanner.Scan() {
+		cred.Password = scanner.Text()
+	}
+
+	// Lines that aren't metadata we know (notes, URLs) are ignored.
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "username", "user", "login":
+			cred.Username = value
+		case "expiry":
+			expiry, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing pass entry expiry: %w", err)
+			}
+			cred.PasswordExpiryUTC = expiry
+		case "oauth_refresh_token":
+			cred.OAuthRefreshToken = value
+		case "authtype":
+			cred.AuthType = value
+		default:
+			setMetadataField(cred, strings.ToLower(strings.TrimSpace(key)), value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parsing pass entry: %w", err)
+	}
+
+	if cred.Password == "" {
+		return nil, ErrNotFound
+	}
+
+	return cred, nil
+}
+
+func formatUnix(t int64) string {
+	if t == 0 {
+		return ""
+	}
+	return strconv.FormatInt(t, 10)
+}
+
+// parseUnix parses a Unix time written by formatUnix.
+func parseUnix(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
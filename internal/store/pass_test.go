@@ -0,0 +1,200 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// fakePass is a minimal pass(1) stand-in keeping entries as plain files
+// under $FAKE_PASS_DIR, with pass's error messages and exit codes.
+const fakePass = `#!/bin/sh
+set -e
+cmd=$1; shift
+case "$cmd" in
+show)
+	f="$FAKE_PASS_DIR/$1.gpg"
+	if [ ! -f "$f" ]; then echo "Error: $1 is not in the password store." >&2; exit 1; fi
+	cat "$f" ;;
+insert)
+	while [ "$1" = "--multiline" ] || [ "$1" = "--force" ]; do shift; done
+	mkdir -p "$(dirname "$FAKE_PASS_DIR/$1")"
+	cat > "$FAKE_PASS_DIR/$1.gpg" ;;
+rm)
+	[ "$1" = "--force" ] && shift
+	f="$FAKE_PASS_DIR/$1.gpg"
+	if [ ! -f "$f" ]; then echo "Error: $1 is not in the password store." >&2; exit 1; fi
+	rm "$f" ;;
+*)
+	echo "unknown command $cmd" >&2; exit 2 ;;
+esac
+`
+
+func installFakePass(t *testing.T) string {
+	t.Helper()
+
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pass"), []byte(fakePass), 0755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	t.Setenv("FAKE_PASS_DIR", dir)
+	return dir
+}
+
+func TestPassStoreRoundTrip(t *testing.T) {
+	dir := installFakePass(t)
+	p := NewPassStore("", "")
+
+	if _, err := p.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000}
+	if err := p.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "git", "gitlab.com", "org1.gpg"))
+	if err != nil {
+		t.Fatalf("entry not at git/gitlab.com/org1: %v", err)
+	}
+	if want := "glpat-abc\nusername: oauth2\nexpiry: 1700000000\n"; string(raw) != want {
+		t.Errorf("entry = %q, want %q", raw, want)
+	}
+
+	got, err := p.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", *got, *cred)
+	}
+
+	if err := p.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := p.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+	if err := p.Erase("gitlab.com/org1"); err != nil {
+		t.Errorf("Erase() of missing entry error = %v", err)
+	}
+}
+
+func TestPassStoreList(t *testing.T) {
+	dir := installFakePass(t)
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+	p := NewPassStore("", "")
+
+	for _, ns := range []string{"gitlab.com/org2", "gitlab.com/org1", "github.com"} {
+		if err := p.Store(ns, &Credential{Password: "x"}); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	for _, other := range []string{"email/work.gpg", ".git/objects/x.gpg", "git/notes.txt"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, other)), 0755)
+		os.WriteFile(filepath.Join(dir, other), []byte("x"), 0600)
+	}
+
+	got, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"github.com", "gitlab.com/org1", "gitlab.com/org2"}; !slices.Equal(got, want) {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+
+	t.Setenv("PASSWORD_STORE_DIR", filepath.Join(dir, "missing"))
+	if got, err := p.List(); err != nil || len(got) != 0 {
+		t.Errorf("List() of missing store = %q, %v, want empty", got, err)
+	}
+}
+
+func TestPassStoreListGopass(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1 $2\" = \"ls --flat\" ] || exit 2\nprintf 'email/work\\nwork/github.com/acme/token\\nwork/gitlab.com/token\\nwork/readme\\n'\n"
+	if err := os.WriteFile(filepath.Join(bin, "gopass"), []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	p := NewPassStore(filepath.Join(bin, "gopass"), "work/{namespace}/token")
+	got, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"github.com/acme", "gitlab.com"}; !slices.Equal(got, want) {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+}
+
+func TestPassStoreErrors(t *testing.T) {
+	p := NewPassStore("git-credentials-org-no-such-pass", "")
+	if _, err := p.Get("gitlab.com/org1"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Get() with missing binary error = %v, want exec.ErrNotFound", err)
+	}
+	if err := p.Erase("gitlab.com/org1"); err == nil {
+		t.Error("Erase() with missing binary should fail")
+	}
+
+	// gpg failures mentioning "not found" aren't missing entries.
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'gpg: decryption failed: No secret key' >&2\necho 'gpg: public key 0xDEADBEEF not found' >&2\nexit 2\n"
+	if err := os.WriteFile(filepath.Join(bin, "pass"), []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	p = NewPassStore(filepath.Join(bin, "pass"), "")
+	if _, err := p.Get("gitlab.com/org1"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() with gpg failure error = %v, want a real error", err)
+	}
+	if err := p.Erase("gitlab.com/org1"); err == nil {
+		t.Error("Erase() with gpg failure should fail")
+	}
+}
+
+func TestPassStorePathTemplate(t *testing.T) {
+	dir := installFakePass(t)
+	p := NewPassStore("", "work/{namespace}/token")
+
+	if err := p.Store("github.com/acme", &Credential{Username: "x-access-token", Password: "ghp_x"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "github.com", "acme", "token.gpg")); err != nil {
+		t.Errorf("entry not at templated path: %v", err)
+	}
+}
+
+func TestParsePassEntry(t *testing.T) {
+	entry := "tok\nURL: https://gitlab.com\nlogin: oauth2\nnotes without colon\noauth_refresh_token: r1\n"
+
+	got, err := parsePassEntry([]byte(entry))
+	if err != nil {
+		t.Fatalf("parsePassEntry() error = %v", err)
+	}
+	want := Credential{Username: "oauth2", Password: "tok", OAuthRefreshToken: "r1"}
+	if *got != want {
+		t.Errorf("parsePassEntry() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestParsePassEntryBadMetadata(t *testing.T) {
+	entry := "tok\nlogin: oauth2\ncreated_at: 2024-01-01\nlast_used_at: 1700000000\nprovider: gitlab\n"
+
+	got, err := parsePassEntry([]byte(entry))
+	if err != nil {
+		t.Fatalf("parsePassEntry() error = %v, want a hand-edited time ignored", err)
+	}
+	want := Credential{Username: "oauth2", Password: "tok", LastUsedAt: 1700000000, Provider: "gitlab"}
+	if *got != want {
+		t.Errorf("parsePassEntry() = %+v, want %+v", *got, want)
+	}
+
+	if _, err := parsePassEntry([]byte("tok\nexpiry: tomorrow\n")); err == nil {
+		t.Error("parsePassEntry() should reject an expiry that doesn't parse")
+	}
+}
@@ -0,0 +1,491 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretServiceName      = "org.freedesktop.secrets"
+	secretServicePath      = "/org/freedesktop/secrets"
+	secretServiceIface     = "org.freedesktop.Secret.Service"
+	secretCollectionIface  = "org.freedesktop.Secret.Collection"
+	secretItemIface        = "org.freedesktop.Secret.Item"
+	secretSessionIface     = "org.freedesktop.Secret.Session"
+	secretPromptIface      = "org.freedesktop.Secret.Prompt"
+	secretCollectionPrefix = "/org/freedesktop/secrets/collection/"
+
+	// secretServiceApplication is the value of the "application" attribute
+	// on every item we create, so our entries can be found (and told apart
+	// from other applications') in Seahorse and by attribute search.
+	secretServiceApplication = "git-credentials-org"
+
+	defaultSecretServiceLabel = "git-credentials-org: {namespace}"
+	secretServicePromptWait   = 2 * time.Minute
+)
+
+// secretService is the subset of the org.freedesktop.Secret API the store
+// needs. Items are addressed by object path. The D-Bus implementation is
+// replaced by an in-memory stand-in in tests.
+type secretService interface {
+	Search(collection string, attrs map[string]string) ([]dbus.ObjectPath, error)
+	GetSecret(item dbus.ObjectPath) ([]byte, error)
+	Attributes(item dbus.ObjectPath) (map[string]string, error)
+	CreateItem(collection, label string, attrs map[string]string, secret []byte) (dbus.ObjectPath, error)
+	Delete(item dbus.ObjectPath) error
+	Close() error
+}
+
+// SecretServiceStore keeps each namespace as an item in a freedesktop.org
+// Secret Service collection (GNOME Keyring, KWallet, KeePassXC), talking
+// to the service over the D-Bus session bus.
+type SecretServiceStore struct {
+	collection string
+	label      string
+	attributes map[string]string
+
+	// providerForHost names the provider recorded in the "provider" attribute.
+	providerForHost func(host string) string
+	connect         func() (secretService, error)
+}
+
+// NewSecretServiceStore returns a store using the given collection name or
+// alias ("default" if empty). label is the item label template, where
+// {namespace}, {host} and {provider} are substituted. attributes are extra
+// static attributes set on every item.
+func NewSecretServiceStore(collection, label string, attributes map[string]string, providerForHost func(string) string) *SecretServiceStore {
+	if collection == "" {
+		collection = "default"
+	}
+	if label == "" {
+		label = defaultSecretServiceLabel
+	}
+	return &SecretServiceStore{
+		collection:      collection,
+		label:           label,
+		attributes:      attributes,
+		providerForHost: providerForHost,
+		connect:         connectSecretService,
+	}
+}
+
+func (s *SecretServiceStore) Name() string {
+	return "secretservice"
+}
+
+// lookupAttributes identifies a namespace's item.
+func (s *SecretServiceStore) lookupAttributes(namespace string) map[string]string {
+	return map[string]string{
+		"application": secretServiceApplication,
+		"namespace":   namespace,
+	}
+}
+
+// itemAttributes returns all attributes stored on a namespace's item.
+func (s *SecretServiceStore) itemAttributes(namespace string) map[string]string {
+	attrs := make(map[string]string, len(s.attributes)+4)
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	for k, v := range s.lookupAttributes(namespace) {
+		attrs[k] = v
+	}
+	if host := namespaceHost(namespace); host != "" {
+		attrs["host"] = host
+		if s.providerForHost != nil {
+			attrs["provider"] = s.providerForHost(host)
+		}
+	}
+	return attrs
+}
+
+func (s *SecretServiceStore) itemLabel(attrs map[string]string) string {
+	return strings.NewReplacer(
+		"{namespace}", attrs["namespace"],
+		"{host}", attrs["host"],
+		"{provider}", attrs["provider"],
+	).Replace(s.label)
+}
+
+func (s *SecretServiceStore) Get(namespace string) (*Credential, error) {
+	svc, err := s.connect()
+	if err != nil {
+		return nil, fmt.Errorf("secretservice get %q: %w", namespace, err)
+	}
+	defer svc.Close()
+
+	items, err := svc.Search(s.collection, s.lookupAttributes(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("secretservice get %q: %w", namespace, err)
+	}
+	if len(items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	data, err := svc.GetSecret(items[0])
+	if err != nil {
+		return nil, fmt.Errorf("secretservice get %q: %w", namespace, err)
+	}
+
+	var cred Credential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, fmt.Errorf("secretservice unmarshal %q: %w", namespace, err)
+	}
+
+	return &cred, nil
+}
+
+func (s *SecretServiceStore) Store(namespace string, cred *Credential) error {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return fmt.Errorf("secretservice marshal: %w", err)
+	}
+
+	svc, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("secretservice store %q: %w", namespace, err)
+	}
+	defer svc.Close()
+
+	existing, err := svc.Search(s.collection, s.lookupAttributes(namespace))
+	if err != nil {
+		return fmt.Errorf("secretservice store %q: %w", namespace, err)
+	}
+
+	attrs := s.itemAttributes(namespace)
+	item, err := svc.CreateItem(s.collection, s.itemLabel(attrs), attrs, data)
+	if err != nil {
+		return fmt.Errorf("secretservice store %q: %w", namespace, err)
+	}
+
+	// CreateItem only replaces items with identical attributes; drop stale
+	// ones left behind after e.g. the configured attributes changed.
+	for _, old := range existing {
+		if old == item {
+			continue
+		}
+		if err := svc.Delete(old); err != nil {
+			return fmt.Errorf("secretservice store %q: removing stale item: %w", namespace, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SecretServiceStore) Erase(namespace string) error {
+	svc, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("secretservice erase %q: %w", namespace, err)
+	}
+	defer svc.Close()
+
+	items, err := svc.Search(s.collection, s.lookupAttributes(namespace))
+	if err != nil {
+		return fmt.Errorf("secretservice erase %q: %w", namespace, err)
+	}
+
+	for _, item := range items {
+		if err := svc.Delete(item); err != nil {
+			return fmt.Errorf("secretservice erase %q: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// List returns the namespaces of our items in the collection, sorted.
+func (s *SecretServiceStore) List() ([]string, error) {
+	svc, err := s.connect()
+	if err != nil {
+		return nil, fmt.Errorf("secretservice list: %w", err)
+	}
+	defer svc.Close()
+
+	items, err := svc.Search(s.collection, map[string]string{"application": secretServiceApplication})
+	if err != nil {
+		return nil, fmt.Errorf("secretservice list: %w", err)
+	}
+
+	var namespaces []string
+	for _, item := range items {
+		attrs, err := svc.Attributes(item)
+		if err != nil {
+			return nil, fmt.Errorf("secretservice list: %w", err)
+		}
+		if ns := attrs["namespace"]; ns != "" && !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// SecretItem is an item of another application in the Secret Service.
+type SecretItem struct {
+	Attributes map[string]string
+	Secret     []byte
+}
+
+// SearchSecretService returns the items in collection matching attrs, for
+// importing credentials other applications saved.
+func SearchSecretService(collection string, attrs map[string]string) ([]SecretItem, error) {
+	svc, err := connectSecretService()
+	if err != nil {
+		return nil, err
+	}
+	defer svc.Close()
+	return searchSecretItems(svc, collection, attrs)
+}
+
+func searchSecretItems(svc secretService, collection string, attrs map[string]string) ([]SecretItem, error) {
+	paths, err := svc.Search(collection, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]SecretItem, 0, len(paths))
+	for _, path := range paths {
+		itemAttrs, err := svc.Attributes(path)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := svc.GetSecret(path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, SecretItem{Attributes: itemAttrs, Secret: secret})
+	}
+	return items, nil
+}
+
+// namespaceHost returns the host part of a namespace like
+// "gitlab.com/org1", or empty string for rule-based namespaces that don't
+// start with a host.
+func namespaceHost(namespace string) string {
+	host, _, _ := strings.Cut(namespace, "/")
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return ""
+}
+
+// dbusSecret is the Secret struct of the Secret Service API (signature oayays).
+type dbusSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// dbusSecretService talks to the Secret Service over the session bus using
+// an unencrypted ("plain") session; the bus itself is local to the user.
+type dbusSecretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+func connectSecretService() (secretService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to session bus: %w", err)
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("opening secret service session: %w", err)
+	}
+
+	return &dbusSecretService{conn: conn, session: session}, nil
+}
+
+func (d *dbusSecretService) service() dbus.BusObject {
+	return d.conn.Object(secretServiceName, secretServicePath)
+}
+
+// collectionPath resolves a collection alias ("default", "session") or
+// name ("login") to its object path.
+func (d *dbusSecretService) collectionPath(name string) (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	if err := d.service().Call(secretServiceIface+".ReadAlias", 0, name).Store(&path); err != nil {
+		return "", fmt.Errorf("resolving collection %q: %w", name, err)
+	}
+	if path != "/" {
+		return path, nil
+	}
+
+	path = dbus.ObjectPath(secretCollectionPrefix + name)
+
+	prop, err := d.service().GetProperty(secretServiceIface + ".Collections")
+	if err != nil {
+		return "", fmt.Errorf("listing collections: %w", err)
+	}
+	collections, _ := prop.Value().([]dbus.ObjectPath)
+	for _, c := range collections {
+		if c == path {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("collection %q not found", name)
+}
+
+func (d *dbusSecretService) Search(collection string, attrs map[string]string) ([]dbus.ObjectPath, error) {
+	path, err := d.collectionPath(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []dbus.ObjectPath
+	err = d.conn.Object(secretServiceName, path).
+		Call(secretCollectionIface+".SearchItems", 0, attrs).
+		Store(&items)
+	if err != nil {
+		return nil, fmt.Errorf("searching items: %w", err)
+	}
+	return items, nil
+}
+
+func (d *dbusSecretService) GetSecret(item dbus.ObjectPath) ([]byte, error) {
+	if err := d.unlock(item); err != nil {
+		return nil, err
+	}
+
+	var secret dbusSecret
+	err := d.conn.Object(secretServiceName, item).
+		Call(secretItemIface+".GetSecret", 0, d.session).
+		Store(&secret)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret: %w", err)
+	}
+	return secret.Value, nil
+}
+
+func (d *dbusSecretService) Attributes(item dbus.ObjectPath) (map[string]string, error) {
+	prop, err := d.conn.Object(secretServiceName, item).GetProperty(secretItemIface + ".Attributes")
+	if err != nil {
+		return nil, fmt.Errorf("reading item attributes: %w", err)
+	}
+	attrs, _ := prop.Value().(map[string]string)
+	return attrs, nil
+}
+
+func (d *dbusSecretService) CreateItem(collection, label string, attrs map[string]string, value []byte) (dbus.ObjectPath, error) {
+	path, err := d.collectionPath(collection)
+	if err != nil {
+		return "", err
+	}
+	if err := d.unlock(path); err != nil {
+		return "", err
+	}
+
+	props := map[string]dbus.Variant{
+		secretItemIface + ".Label":      dbus.MakeVariant(label),
+		secretItemIface + ".Attributes": dbus.MakeVariant(attrs),
+	}
+	secret := dbusSecret{
+		Session:     d.session,
+		Value:       value,
+		ContentType: "application/json",
+	}
+
+	var item, prompt dbus.ObjectPath
+	err = d.conn.Object(secretServiceName, path).
+		Call(secretCollectionIface+".CreateItem", 0, props, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return "", fmt.Errorf("creating item: %w", err)
+	}
+
+	result, err := d.prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	if item == "/" {
+		item, _ = result.Value().(dbus.ObjectPath)
+	}
+	return item, nil
+}
+
+func (d *dbusSecretService) Delete(item dbus.ObjectPath) error {
+	var prompt dbus.ObjectPath
+	err := d.conn.Object(secretServiceName, item).
+		Call(secretItemIface+".Delete", 0).
+		Store(&prompt)
+	if err != nil {
+		return fmt.Errorf("deleting item: %w", err)
+	}
+	_, err = d.prompt(prompt)
+	return err
+}
+
+func (d *dbusSecretService) Close() error {
+	d.conn.Object(secretServiceName, d.session).Call(secretSessionIface+".Close", 0)
+	return d.conn.Close()
+}
+
+// unlock unlocks an item or collection, showing the service's unlock
+// prompt if needed.
+func (d *dbusSecretService) unlock(path dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := d.service().
+		Call(secretServiceIface+".Unlock", 0, []dbus.ObjectPath{path}).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return fmt.Errorf("unlocking %s: %w", path, err)
+	}
+	_, err = d.prompt(prompt)
+	return err
+}
+
+// prompt runs a Secret Service prompt and waits for it to complete. A
+// prompt path of "/" means none was needed.
+func (d *dbusSecretService) prompt(path dbus.ObjectPath) (dbus.Variant, error) {
+	if path == "/" || path == "" {
+		return dbus.Variant{}, nil
+	}
+
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretPromptIface),
+		dbus.WithMatchMember("Completed"),
+	)
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("watching prompt: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 1)
+	d.conn.Signal(signals)
+	defer d.conn.RemoveSignal(signals)
+
+	if err := d.conn.Object(secretServiceName, path).Call(secretPromptIface+".Prompt", 0, "").Err; err != nil {
+		return dbus.Variant{}, fmt.Errorf("showing prompt: %w", err)
+	}
+
+	timeout := time.After(secretServicePromptWait)
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Path != path || len(sig.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := sig.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errors.New("secret service prompt dismissed")
+			}
+			result, _ := sig.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("timed out waiting for secret service prompt")
+		}
+	}
+}
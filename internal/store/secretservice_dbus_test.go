@@ -0,0 +1,194 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// dbusFakeService exports a minimal Secret Service on a private bus so the
+// real D-Bus client code can be exercised without a keyring daemon.
+type dbusFakeService struct {
+	conn *dbus.Conn
+
+	mu    sync.Mutex
+	items map[dbus.ObjectPath]*dbusFakeItem
+	next  int
+}
+
+type dbusFakeItem struct {
+	path   dbus.ObjectPath
+	svc    *dbusFakeService
+	attrs  map[string]string
+	label  string
+	secret []byte
+}
+
+const dbusFakeCollection = dbus.ObjectPath(secretCollectionPrefix + "login")
+
+func (s *dbusFakeService) OpenSession(algorithm string, _ dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "/", dbus.MakeFailedError(dbus.ErrMsgInvalidArg)
+	}
+	return dbus.MakeVariant(""), "/org/freedesktop/secrets/session/1", nil
+}
+
+func (s *dbusFakeService) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	if name == "default" {
+		return dbusFakeCollection, nil
+	}
+	return "/", nil
+}
+
+func (s *dbusFakeService) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return objects, "/", nil
+}
+
+func (s *dbusFakeService) SearchItems(attrs map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []dbus.ObjectPath
+	for path, item := range s.items {
+		if matchAttrs(item.attrs, attrs) {
+			found = append(found, path)
+		}
+	}
+	return found, nil
+}
+
+func (s *dbusFakeService) CreateItem(props map[string]dbus.Variant, secret dbusSecret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	attrs, _ := props[secretItemIface+".Attributes"].Value().(map[string]string)
+	label, _ := props[secretItemIface+".Label"].Value().(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replace {
+		for path, item := range s.items {
+			if len(item.attrs) == len(attrs) && matchAttrs(item.attrs, attrs) {
+				item.label, item.secret = label, secret.Value
+				return path, "/", nil
+			}
+		}
+	}
+
+	s.next++
+	path := dbus.ObjectPath(fmt.Sprintf("%s/%d", dbusFakeCollection, s.next))
+	item := &dbusFakeItem{path: path, svc: s, attrs: attrs, label: label, secret: secret.Value}
+	s.items[path] = item
+	if err := s.conn.Export(item, path, secretItemIface); err != nil {
+		return "/", "/", dbus.MakeFailedError(err)
+	}
+	return path, "/", nil
+}
+
+func (i *dbusFakeItem) GetSecret(session dbus.ObjectPath) (dbusSecret, *dbus.Error) {
+	return dbusSecret{Session: session, Value: i.secret, ContentType: "application/json"}, nil
+}
+
+func (i *dbusFakeItem) Delete() (dbus.ObjectPath, *dbus.Error) {
+	i.svc.mu.Lock()
+	delete(i.svc.items, i.path)
+	i.svc.mu.Unlock()
+	i.svc.conn.Export(nil, i.path, secretItemIface)
+	return "/", nil
+}
+
+type dbusFakeSession struct{}
+
+func (dbusFakeSession) Close() *dbus.Error { return nil }
+
+// startPrivateBus runs a dbus-daemon for the test and points the session
+// bus address at it.
+func startPrivateBus(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe() error = %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("starting dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+func TestSecretServiceStoreOverDBus(t *testing.T) {
+	startPrivateBus(t)
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatalf("ConnectSessionBus() error = %v", err)
+	}
+	defer conn.Close()
+
+	fake := &dbusFakeService{conn: conn, items: make(map[dbus.ObjectPath]*dbusFakeItem)}
+	for _, export := range []struct {
+		v     any
+		path  dbus.ObjectPath
+		iface string
+	}{
+		{fake, secretServicePath, secretServiceIface},
+		{fake, dbusFakeCollection, secretCollectionIface},
+		{dbusFakeSession{}, "/org/freedesktop/secrets/session/1", secretSessionIface},
+	} {
+		if err := conn.Export(export.v, export.path, export.iface); err != nil {
+			t.Fatalf("Export(%s) error = %v", export.iface, err)
+		}
+	}
+	if reply, err := conn.RequestName(secretServiceName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("RequestName() = %v, %v", reply, err)
+	}
+
+	s := NewSecretServiceStore("", "", nil, func(string) string { return "github" })
+
+	cred := &Credential{Username: "x-access-token", Password: "ghp_abc"}
+	if err := s.Store("github.com/acme", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := s.Store("github.com/acme", &Credential{Username: "x-access-token", Password: "ghp_new"}); err != nil {
+		t.Fatalf("Store() again error = %v", err)
+	}
+
+	got, err := s.Get("github.com/acme")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Password != "ghp_new" {
+		t.Errorf("Get() password = %q, want %q", got.Password, "ghp_new")
+	}
+
+	fake.mu.Lock()
+	n := len(fake.items)
+	fake.mu.Unlock()
+	if n != 1 {
+		t.Errorf("items on bus = %d, want 1", n)
+	}
+
+	if err := s.Erase("github.com/acme"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := s.Get("github.com/acme"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+}
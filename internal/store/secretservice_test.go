@@ -0,0 +1,214 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeSecretService is an in-process stand-in for the Secret Service,
+// with the same attribute matching and replace-on-create semantics.
+type fakeSecretService struct {
+	items  map[dbus.ObjectPath]*fakeSecretItem
+	nextID int
+}
+
+type fakeSecretItem struct {
+	collection string
+	label      string
+	attrs      map[string]string
+	secret     []byte
+}
+
+func newFakeSecretService() *fakeSecretService {
+	return &fakeSecretService{items: make(map[dbus.ObjectPath]*fakeSecretItem)}
+}
+
+func (f *fakeSecretService) Search(collection string, attrs map[string]string) ([]dbus.ObjectPath, error) {
+	var found []dbus.ObjectPath
+	for path, item := range f.items {
+		if item.collection == collection && matchAttrs(item.attrs, attrs) {
+			found = append(found, path)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeSecretService) GetSecret(item dbus.ObjectPath) ([]byte, error) {
+	it, ok := f.items[item]
+	if !ok {
+		return nil, fmt.Errorf("no such item %s", item)
+	}
+	return it.secret, nil
+}
+
+func (f *fakeSecretService) Attributes(item dbus.ObjectPath) (map[string]string, error) {
+	it, ok := f.items[item]
+	if !ok {
+		return nil, fmt.Errorf("no such item %s", item)
+	}
+	return it.attrs, nil
+}
+
+func (f *fakeSecretService) CreateItem(collection, label string, attrs map[string]string, secret []byte) (dbus.ObjectPath, error) {
+	for path, item := range f.items {
+		if item.collection == collection && len(item.attrs) == len(attrs) && matchAttrs(item.attrs, attrs) {
+			item.label, item.secret = label, secret
+			return path, nil
+		}
+	}
+
+	f.nextID++
+	path := dbus.ObjectPath(fmt.Sprintf("%s%s/%d", secretCollectionPrefix, collection, f.nextID))
+	f.items[path] = &fakeSecretItem{collection: collection, label: label, attrs: attrs, secret: secret}
+	return path, nil
+}
+
+func (f *fakeSecretService) Delete(item dbus.ObjectPath) error {
+	delete(f.items, item)
+	return nil
+}
+
+func (f *fakeSecretService) Close() error { return nil }
+
+func matchAttrs(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestSecretServiceStore(fake *fakeSecretService, attrs map[string]string) *SecretServiceStore {
+	s := NewSecretServiceStore("login", "Git: {namespace} ({provider})", attrs, func(host string) string {
+		return "gitlab"
+	})
+	s.connect = func() (secretService, error) { return fake, nil }
+	return s
+}
+
+func TestSecretServiceStoreRoundTrip(t *testing.T) {
+	fake := newFakeSecretService()
+	s := newTestSecretServiceStore(fake, map[string]string{"team": "platform"})
+
+	if _, err := s.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() on empty store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000}
+	if err := s.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got, err := s.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", *got, *cred)
+	}
+
+	if len(fake.items) != 1 {
+		t.Fatalf("items = %d, want 1", len(fake.items))
+	}
+	for _, item := range fake.items {
+		want := map[string]string{
+			"application": "git-credentials-org",
+			"namespace":   "gitlab.com/org1",
+			"host":        "gitlab.com",
+			"provider":    "gitlab",
+			"team":        "platform",
+		}
+		if len(item.attrs) != len(want) || !matchAttrs(item.attrs, want) {
+			t.Errorf("item attributes = %v, want %v", item.attrs, want)
+		}
+		if item.label != "Git: gitlab.com/org1 (gitlab)" {
+			t.Errorf("item label = %q", item.label)
+		}
+		if item.collection != "login" {
+			t.Errorf("item collection = %q, want login", item.collection)
+		}
+	}
+
+	if err := s.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := s.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretServiceStoreReplacesStaleItems(t *testing.T) {
+	fake := newFakeSecretService()
+
+	old := newTestSecretServiceStore(fake, map[string]string{"team": "old"})
+	if err := old.Store("gitlab.com/org1", &Credential{Username: "oauth2", Password: "v1"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	s := newTestSecretServiceStore(fake, nil)
+	for _, pw := range []string{"v2", "v3"} {
+		if err := s.Store("gitlab.com/org1", &Credential{Username: "oauth2", Password: pw}); err != nil {
+			t.Fatalf("Store(%s) error = %v", pw, err)
+		}
+	}
+
+	if len(fake.items) != 1 {
+		t.Errorf("items = %d, want 1", len(fake.items))
+	}
+	got, err := s.Get("gitlab.com/org1")
+	if err != nil || got.Password != "v3" {
+		t.Errorf("Get() = %+v, %v, want v3", got, err)
+	}
+}
+
+func TestSecretServiceStoreList(t *testing.T) {
+	fake := newFakeSecretService()
+	s := newTestSecretServiceStore(fake, nil)
+	for _, ns := range []string{"gitlab.com/org2", "gitlab.com/org1"} {
+		if err := s.Store(ns, &Credential{Username: "oauth2", Password: "tok"}); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	fake.CreateItem("login", "other", map[string]string{"application": "firefox", "namespace": "x"}, nil)
+
+	got, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"gitlab.com/org1", "gitlab.com/org2"}; !slices.Equal(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestSearchSecretItems(t *testing.T) {
+	fake := newFakeSecretService()
+	fake.CreateItem("default", "github.com", map[string]string{"xdg:schema": "org.git.Password", "server": "github.com"}, []byte("ghp_x"))
+	fake.CreateItem("default", "other", map[string]string{"xdg:schema": "org.gnome.Other"}, []byte("y"))
+
+	items, err := searchSecretItems(fake, "default", map[string]string{"xdg:schema": "org.git.Password"})
+	if err != nil {
+		t.Fatalf("searchSecretItems() error = %v", err)
+	}
+	if len(items) != 1 || items[0].Attributes["server"] != "github.com" || string(items[0].Secret) != "ghp_x" {
+		t.Errorf("searchSecretItems() = %+v", items)
+	}
+}
+
+func TestNamespaceHost(t *testing.T) {
+	tests := map[string]string{
+		"gitlab.com/org1":           "gitlab.com",
+		"git.example.com:8443/team": "git.example.com:8443",
+		"github.com":                "github.com",
+		"acme":                      "",
+		"localhost/org":             "localhost",
+	}
+	for ns, want := range tests {
+		if got := namespaceHost(ns); got != want {
+			t.Errorf("namespaceHost(%q) = %q, want %q", ns, got, want)
+		}
+	}
+}
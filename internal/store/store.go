@@ -3,15 +3,120 @@ package store
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/imcitius/git-credentials-org/internal/config"
+	"github.com/imcitius/git-credentials-org/internal/provider"
 )
 
 var ErrNotFound = errors.New("credentials not found")
 
+// ErrReadOnly is returned by Store and Erase of a backend configured as
+// read-only.
+var ErrReadOnly = errors.New("backend is read-only")
+
+// Password manager backends (1Password, Bitwarden) share item titles and
+// custom field names, so teams can migrate items between them.
+const itemTitlePrefix = "git-credentials-org: "
+
+const (
+	// fieldExpiry holds the password expiry as a Unix timestamp.
+	fieldExpiry = "password_expiry_utc"
+	// fieldRefreshToken holds the OAuth refresh token.
+	fieldRefreshToken = "oauth_refresh_token"
+	// fieldAuthType holds the auth scheme of a pre-encoded credential.
+	fieldAuthType = "authtype"
+
+	// Metadata fields; timestamps are Unix times.
+	fieldCreatedAt     = "created_at"
+	fieldLastUsedAt    = "last_used_at"
+	fieldProvider      = "provider"
+	fieldOrigin        = "origin"
+	fieldHelperVersion = "helper_version"
+)
+
+// itemTitle returns the password manager item title for a namespace,
+// e.g. "git-credentials-org: gitlab.com/org1".
+func itemTitle(namespace string) string {
+	return itemTitlePrefix + namespace
+}
+
 type Credential struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// PasswordExpiryUTC is the Unix time after which Password stops
+	// working, as reported by git. Zero means no known expiry.
+	PasswordExpiryUTC int64 `json:"password_expiry_utc,omitempty"`
+
+	// OAuthRefreshToken lets an OAuth helper in front of us obtain a new
+	// Password without prompting.
+	OAuthRefreshToken string `json:"oauth_refresh_token,omitempty"`
+
+	// AuthType is the HTTP auth scheme Password was issued for when git
+	// handed it to us pre-encoded (e.g. "Bearer"). Empty means Basic.
+	AuthType string `json:"authtype,omitempty"`
+
+	// Metadata for auditing and rotating tokens; it is never sent to git.
+	// CreatedAt and LastUsedAt are Unix times, Origin is the host/path of
+	// the request that first stored the credential and HelperVersion the
+	// version of git-credentials-org that did.
+	CreatedAt     int64  `json:"created_at,omitempty"`
+	LastUsedAt    int64  `json:"last_used_at,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	Origin        string `json:"origin,omitempty"`
+	HelperVersion string `json:"helper_version,omitempty"`
+}
+
+// metadataField is a named metadata value of a Credential.
+type metadataField struct {
+	name, value string
+}
+
+// metadataFields returns the metadata of c as named string values, for
+// backends storing it in separate fields. Unset values are empty.
+func metadataFields(c *Credential) []metadataField {
+	return []metadataField{
+		{fieldCreatedAt, formatUnix(c.CreatedAt)},
+		{fieldLastUsedAt, formatUnix(c.LastUsedAt)},
+		{fieldProvider, c.Provider},
+		{fieldOrigin, c.Origin},
+		{fieldHelperVersion, c.HelperVersion},
+	}
+}
+
+// isMetadataField reports whether name is one of the metadata fields.
+func isMetadataField(name string) bool {
+	switch name {
+	case fieldCreatedAt, fieldLastUsedAt, fieldProvider, fieldOrigin, fieldHelperVersion:
+		return true
+	}
+	return false
+}
+
+// setMetadataField sets the metadata field name of c from its stored
+// value. Other names are ignored, and so are times that don't parse (e.g.
+// edited by hand): metadata is never sent to git, so it mustn't get in the
+// way of authenticating.
+func setMetadataField(c *Credential, name, value string) {
+	switch name {
+	case fieldCreatedAt:
+		c.CreatedAt, _ = parseUnix(value)
+	case fieldLastUsedAt:
+		c.LastUsedAt, _ = parseUnix(value)
+	case fieldProvider:
+		c.Provider = value
+	case fieldOrigin:
+		c.Origin = value
+	case fieldHelperVersion:
+		c.HelperVersion = value
+	}
+}
+
+// Expired reports whether the credential has a known expiry at or before now.
+func (c *Credential) Expired(now time.Time) bool {
+	return c.PasswordExpiryUTC != 0 && c.PasswordExpiryUTC <= now.Unix()
 }
 
 type CredentialStore interface {
@@ -21,18 +126,67 @@ type CredentialStore interface {
 	Name() string
 }
 
+// Updater is implemented by stores combining others. Update rewrites a
+// credential where it is held, e.g. to record its last use, instead of
+// where Store would write a new one.
+type Updater interface {
+	Update(namespace string, cred *Credential) error
+}
+
+// Lister is implemented by backends that can enumerate the namespaces they
+// hold credentials for.
+type Lister interface {
+	List() ([]string, error)
+}
+
+// New returns the store for a backend name. The backend's type is its name
+// unless [backends.<name>] sets type, which allows several instances of
+// one type (e.g. two exec plugins).
 func New(backendName string, cfg *config.Config) (CredentialStore, error) {
-	switch backendName {
+	bc := cfg.Backends[backendName]
+	kind := backendName
+	if bc.Type != "" {
+		kind = bc.Type
+	}
+
+	switch kind {
 	case "keychain":
 		return NewKeychainStore(), nil
 	case "onepassword", "1password":
-		bc := cfg.Backends[backendName]
 		vault := bc.Vault
 		if vault == "" {
 			vault = "Private"
 		}
 		return NewOnePasswordStore(vault, bc.Account), nil
+	case "secretservice":
+		providerForHost := func(host string) string {
+			return provider.ForHost(host, cfg.ProviderForHost(host)).Name()
+		}
+		return NewSecretServiceStore(bc.Collection, bc.Label, bc.Attributes, providerForHost), nil
+	case "file":
+		path := bc.Path
+		if path == "" {
+			path = filepath.Join(filepath.Dir(config.DefaultConfigPath()), "credentials.age")
+		}
+		return NewFileStore(config.ExpandPath(path), config.ExpandPath(bc.Identity), config.ExpandPath(bc.PassphraseFile)), nil
+	case "keepass":
+		path := bc.Path
+		if path == "" {
+			path = filepath.Join(filepath.Dir(config.DefaultConfigPath()), "credentials.kdbx")
+		}
+		return NewKeePassStore(config.ExpandPath(path), bc.Group, config.ExpandPath(bc.KeyFile), config.ExpandPath(bc.PassphraseFile)), nil
+	case "pass":
+		return NewPassStore(bc.Command, bc.Path), nil
+	case "vault":
+		return NewVaultStore(bc.Address, bc.Mount, bc.Path, config.ExpandPath(bc.TokenFile), bc.ReadOnly), nil
+	case "exec":
+		if bc.Command == "" {
+			return nil, fmt.Errorf("backend %s: command is required", backendName)
+		}
+		return NewExecStore(config.ExpandPath(bc.Command), bc.Args), nil
+	case "bitwarden":
+		return NewBitwardenStore(bc.Folder, bc.Organization, bc.Collection), nil
 	default:
-		return nil, fmt.Errorf("unknown backend: %s", backendName)
+		return nil, fmt.Errorf("unknown backend: %s", kind)
 	}
 }
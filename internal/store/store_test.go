@@ -0,0 +1,112 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCredentialExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		expiry int64
+		want   bool
+	}{
+		{name: "no expiry", expiry: 0, want: false},
+		{name: "future", expiry: 1700000060, want: false},
+		{name: "exactly now", expiry: 1700000000, want: true},
+		{name: "past", expiry: 1699999999, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Credential{PasswordExpiryUTC: tt.expiry}
+			if got := c.Expired(now); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnePasswordParseItemJSON(t *testing.T) {
+	data := []byte(`{"fields":[
+		{"id":"username","label":"username","value":"oauth2"},
+		{"id":"password","label":"password","value":"glpat-abc"},
+		{"id":"x1","label":"password_expiry_utc","value":"1700000000"},
+		{"id":"x2","label":"oauth_refresh_token","value":"refresh-abc"},
+		{"id":"x3","label":"created_at","value":"1690000000"},
+		{"id":"x4","label":"provider","value":"gitlab"}
+	]}`)
+
+	o := NewOnePasswordStore("Private", "")
+	cred, err := o.parseItemJSON(data)
+	if err != nil {
+		t.Fatalf("parseItemJSON() error = %v", err)
+	}
+
+	want := Credential{
+		Username:          "oauth2",
+		Password:          "glpat-abc",
+		PasswordExpiryUTC: 1700000000,
+		OAuthRefreshToken: "refresh-abc",
+		CreatedAt:         1690000000,
+		Provider:          "gitlab",
+	}
+	if *cred != want {
+		t.Errorf("parseItemJSON() = %+v, want %+v", *cred, want)
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	cred := &Credential{
+		Username:      "oauth2",
+		Password:      "glpat-abc",
+		CreatedAt:     1700000000,
+		LastUsedAt:    1700003600,
+		Provider:      "gitlab",
+		Origin:        "gitlab.com/org1/repo.git",
+		HelperVersion: "1.4.0",
+	}
+
+	roundTrips := map[string]func(*Credential) (*Credential, error){
+		"pass": func(c *Credential) (*Credential, error) {
+			return parsePassEntry(formatPassEntry(c))
+		},
+		"vault": func(c *Credential) (*Credential, error) {
+			data := make(map[string]any)
+			for k, v := range vaultData(c) {
+				data[k] = v
+			}
+			return parseVaultData(data)
+		},
+		"bitwarden": func(c *Credential) (*Credential, error) {
+			raw := map[string]any{}
+			setBWCredential(raw, c)
+			data, _ := json.Marshal(raw)
+			var item bwItem
+			if err := json.Unmarshal(data, &item); err != nil {
+				return nil, err
+			}
+			return item.credential()
+		},
+		"keepass": func(c *Credential) (*Credential, error) {
+			entry := newKeePassEntry("gitlab.com/org1", time.Now())
+			setKeePassCredential(entry, c, time.Now())
+			return keePassCredential(entry)
+		},
+	}
+
+	for name, roundTrip := range roundTrips {
+		t.Run(name, func(t *testing.T) {
+			got, err := roundTrip(cred)
+			if err != nil {
+				t.Fatalf("round trip error = %v", err)
+			}
+			if *got != *cred {
+				t.Errorf("round trip = %+v, want %+v", *got, *cred)
+			}
+		})
+	}
+}
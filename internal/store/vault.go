@@ -0,0 +1,259 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVaultAddress      = "https://127.0.0.1:8200"
+	defaultVaultMount        = "secret"
+	defaultVaultPathTemplate = "git/{namespace}"
+)
+
+// VaultStore keeps each namespace as a HashiCorp Vault KV v2 secret,
+// speaking the HTTP API directly. Secret data uses the same keys as the
+// other backends (username, password, password_expiry_utc, ...), so team
+// tokens can also be written with `vault kv put`.
+type VaultStore struct {
+	address      string
+	mount        string
+	pathTemplate string
+	tokenFile    string
+	readOnly     bool
+
+	client *http.Client
+}
+
+// NewVaultStore returns a store for the KV v2 engine at mount on the Vault
+// server at address ($VAULT_ADDR if empty), with secrets at pathTemplate
+// where {namespace} is substituted. The token comes from $VAULT_TOKEN or
+// tokenFile (default ~/.vault-token).
+func NewVaultStore(address, mount, pathTemplate, tokenFile string, readOnly bool) *VaultStore {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		address = defaultVaultAddress
+	}
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	if pathTemplate == "" {
+		pathTemplate = defaultVaultPathTemplate
+	}
+	if tokenFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			tokenFile = filepath.Join(home, ".vault-token")
+		}
+	}
+	return &VaultStore{
+		address:      strings.TrimRight(address, "/"),
+		mount:        strings.Trim(mount, "/"),
+		pathTemplate: pathTemplate,
+		tokenFile:    tokenFile,
+		readOnly:     readOnly,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *VaultStore) Name() string {
+	return "vault"
+}
+
+type vaultSecret struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+func (v *VaultStore) Get(namespace string) (*Credential, error) {
+	body, status, err := v.do(http.MethodGet, v.dataURL(namespace), nil)
+	if err != nil {
+		return nil, fmt.Errorf("vault get %q: %w", namespace, err)
+	}
+	// KV v2 answers 404 for missing and for deleted secrets.
+	if status == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	var secret vaultSecret
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("parsing vault secret: %w", err)
+	}
+
+	return parseVaultData(secret.Data.Data)
+}
+
+func (v *VaultStore) Store(namespace string, cred *Credential) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	payload, err := json.Marshal(map[string]any{"data": vaultData(cred)})
+	if err != nil {
+		return fmt.Errorf("vault store %q: %w", namespace, err)
+	}
+	if _, _, err := v.do(http.MethodPost, v.dataURL(namespace), payload); err != nil {
+		return fmt.Errorf("vault store %q: %w", namespace, err)
+	}
+	return nil
+}
+
+// Erase soft-deletes the latest version, so a wrongly rejected team token
+// can be restored with `vault kv undelete`.
+func (v *VaultStore) Erase(namespace string) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	if _, _, err := v.do(http.MethodDelete, v.dataURL(namespace), nil); err != nil {
+		return fmt.Errorf("vault erase %q: %w", namespace, err)
+	}
+	return nil
+}
+
+// dataURL returns the KV v2 data endpoint URL for a namespace.
+func (v *VaultStore) dataURL(namespace string) string {
+	path := strings.ReplaceAll(v.pathTemplate, "{namespace}", namespace)
+
+	var segments []string
+	for _, s := range strings.Split(v.mount+"/data/"+strings.Trim(path, "/"), "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	return v.address + "/v1/" + strings.Join(segments, "/")
+}
+
+// do performs an authenticated API request. 404 is returned as a status
+// without error; other non-2xx responses are errors carrying Vault's
+// messages.
+func (v *VaultStore) do(method, target string, payload []byte) ([]byte, int, error) {
+	token, err := v.token()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, resp.StatusCode, nil
+	case resp.StatusCode >= 300:
+		return nil, resp.StatusCode, vaultError(resp.StatusCode, body)
+	}
+	return body, resp.StatusCode, nil
+}
+
+func (v *VaultStore) token() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if v.tokenFile != "" {
+		data, err := os.ReadFile(v.tokenFile)
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("reading vault token: %w", err)
+		}
+	}
+
+	return "", errors.New("no vault token (set VAULT_TOKEN or token_file in [backends.vault], or run vault login)")
+}
+
+func vaultError(status int, body []byte) error {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if json.Unmarshal(body, &resp) == nil && len(resp.Errors) > 0 {
+		return fmt.Errorf("vault: %s (HTTP %d)", strings.Join(resp.Errors, "; "), status)
+	}
+	return fmt.Errorf("vault: HTTP %d", status)
+}
+
+// vaultData returns the secret data for a credential. Values are strings,
+// as `vault kv put key=value` writes them.
+func vaultData(cred *Credential) map[string]string {
+	data := map[string]string{
+		"username": cred.Username,
+		"password": cred.Password,
+	}
+	for key, val := range map[string]string{
+		fieldExpiry:       formatUnix(cred.PasswordExpiryUTC),
+		fieldRefreshToken: cred.OAuthRefreshToken,
+		fieldAuthType:     cred.AuthType,
+	} {
+		if val != "" {
+			data[key] = val
+		}
+	}
+	for _, f := range metadataFields(cred) {
+		if f.value != "" {
+			data[f.name] = f.value
+		}
+	}
+	return data
+}
+
+func parseVaultData(data map[string]any) (*Credential, error) {
+	str := func(key string) string {
+		switch v := data[key].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	cred := &Credential{
+		Username:          str("username"),
+		Password:          str("password"),
+		OAuthRefreshToken: str(fieldRefreshToken),
+		AuthType:          str(fieldAuthType),
+	}
+	if expiry := str(fieldExpiry); expiry != "" {
+		t, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing vault %s: %w", fieldExpiry, err)
+		}
+		cred.PasswordExpiryUTC = t
+	}
+	for _, f := range metadataFields(cred) {
+		setMetadataField(cred, f.name, str(f.name))
+	}
+
+	if cred.Password == "" {
+		return nil, ErrNotFound
+	}
+	return cred, nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeVault is an httptest stand-in for a Vault server with a KV v2 engine,
+// keeping only the latest version of each secret.
+type fakeVault struct {
+	token string
+
+	mu      sync.Mutex
+	secrets map[string]map[string]any
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := f.secrets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": data}})
+	case http.MethodPost, http.MethodPut:
+		var body struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.secrets[path] = body.Data
+		w.Write([]byte(`{"data":{"version":1}}`))
+	case http.MethodDelete:
+		delete(f.secrets, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	fake := &fakeVault{token: "s.test", secrets: make(map[string]map[string]any)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_TOKEN", fake.token)
+	return fake, srv
+}
+
+func TestVaultStoreRoundTrip(t *testing.T) {
+	fake, srv := newFakeVault(t)
+	v := NewVaultStore(srv.URL, "", "", "", false)
+
+	if _, err := v.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Fatalf("Get() before store error = %v, want ErrNotFound", err)
+	}
+
+	cred := &Credential{Username: "oauth2", Password: "glpat-abc", PasswordExpiryUTC: 1700000000}
+	if err := v.Store("gitlab.com/org1", cred); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if _, ok := fake.secrets["git/gitlab.com/org1"]; !ok {
+		t.Fatalf("secrets = %v, want git/gitlab.com/org1", fake.secrets)
+	}
+
+	got, err := v.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *got != *cred {
+		t.Errorf("Get() = %+v, want %+v", got, cred)
+	}
+
+	if err := v.Erase("gitlab.com/org1"); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+	if _, err := v.Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() after Erase() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVaultStoreReadsKVPut(t *testing.T) {
+	fake, srv := newFakeVault(t)
+	// As written by `vault kv put secret/teams/gitlab.com/org1 password=... password_expiry_utc=...`.
+	fake.secrets["teams/gitlab.com/org1"] = map[string]any{"password": "glpat-team", "password_expiry_utc": "1700000000"}
+
+	v := NewVaultStore(srv.URL, "secret", "teams/{namespace}", "", false)
+	got, err := v.Get("gitlab.com/org1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := &Credential{Password: "glpat-team", PasswordExpiryUTC: 1700000000}
+	if *got != *want {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+}
+
+func TestVaultStoreReadOnly(t *testing.T) {
+	fake, srv := newFakeVault(t)
+	fake.secrets["git/gitlab.com/org1"] = map[string]any{"username": "oauth2", "password": "glpat-team"}
+
+	v := NewVaultStore(srv.URL, "", "", "", true)
+	if _, err := v.Get("gitlab.com/org1"); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if err := v.Store("gitlab.com/org1", &Credential{Password: "x"}); err != ErrReadOnly {
+		t.Errorf("Store() error = %v, want ErrReadOnly", err)
+	}
+	if err := v.Erase("gitlab.com/org1"); err != ErrReadOnly {
+		t.Errorf("Erase() error = %v, want ErrReadOnly", err)
+	}
+	if fake.secrets["git/gitlab.com/org1"]["password"] != "glpat-team" {
+		t.Errorf("secret modified by read-only store")
+	}
+}
+
+func TestVaultStoreToken(t *testing.T) {
+	_, srv := newFakeVault(t)
+	t.Setenv("VAULT_TOKEN", "")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s.test\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := NewVaultStore(srv.URL, "", "", tokenFile, false).Get("gitlab.com/org1"); err != ErrNotFound {
+		t.Errorf("Get() with token file error = %v, want ErrNotFound", err)
+	}
+
+	os.WriteFile(tokenFile, []byte("s.wrong"), 0600)
+	_, err := NewVaultStore(srv.URL, "", "", tokenFile, false).Get("gitlab.com/org1")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Get() with bad token error = %v, want permission denied", err)
+	}
+
+	_, err = NewVaultStore(srv.URL, "", "", filepath.Join(t.TempDir(), "missing"), false).Get("gitlab.com/org1")
+	if err == nil || !strings.Contains(err.Error(), "no vault token") {
+		t.Errorf("Get() without token error = %v, want no vault token", err)
+	}
+}